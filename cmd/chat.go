@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/repl"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chatPollInterval time.Duration
+	chatHistory      int
+)
+
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Work with a single chat",
+}
+
+var chatOpenCmd = &cobra.Command{
+	Use:   "open [chat-id]",
+	Short: "Open an interactive shell for a chat",
+	Long: `Open a line-oriented shell for a chat. Each line you type is sent as a
+message and new messages are printed as they arrive. Lines starting with a
+slash are commands (/help lists them); start a line with // to send a
+literal slash. Ctrl-D or /quit leaves the chat.
+
+Without a chat ID, an interactive picker lists your chats.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runChatOpen,
+}
+
+func init() {
+	chatOpenCmd.Flags().DurationVar(&chatPollInterval, "interval", 5*time.Second, "how often to check for new messages")
+	chatOpenCmd.Flags().IntVar(&chatHistory, "history", 10, "number of recent messages to show on open")
+	chatCmd.AddCommand(chatOpenCmd)
+	rootCmd.AddCommand(chatCmd)
+}
+
+func runChatOpen(cmd *cobra.Command, args []string) error {
+	if err := requireUser("opening a chat"); err != nil {
+		return err
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var chatID string
+	if len(args) > 0 {
+		chatID = args[0]
+	} else {
+		id, err := pickChat(cmd, client)
+		if err != nil {
+			return err
+		}
+		chatID = id
+	}
+
+	return repl.Run(cmd.Context(), client, chatID, os.Stdin, os.Stdout, repl.Options{
+		PollInterval: chatPollInterval,
+		History:      chatHistory,
+	})
+}
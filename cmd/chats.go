@@ -1,46 +1,59 @@
 package cmd
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"text/tabwriter"
-
 	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/output"
 	"github.com/spf13/cobra"
 )
 
-var chatsJSON bool
+var (
+	chatsJSON bool
+	chatsUser string
+)
 
 var chatsCmd = &cobra.Command{
 	Use:   "chats",
 	Short: "List your Teams chats",
-	RunE:  runChats,
+	Long: `List your Teams chats, or with --user the chats of another user (which requires
+the Chat.ReadBasic.All permission, and works with app-only authentication).`,
+	RunE: runChats,
+}
+
+var chatColumns = []output.Column[graph.Chat]{
+	{Name: "id", Header: "CHAT ID", Value: func(c graph.Chat) string { return c.ID }},
+	{Name: "type", Header: "TYPE", Value: func(c graph.Chat) string { return c.ChatType }},
+	{Name: "name", Header: "NAME", Value: graph.ChatDisplayName},
 }
 
 func init() {
 	chatsCmd.Flags().BoolVar(&chatsJSON, "json", false, "output as JSON")
+	chatsCmd.Flags().MarkDeprecated("json", "use --output json instead")
+	chatsCmd.Flags().StringVar(&chatsUser, "user", "", "list the chats of this user ID or user principal name")
 	rootCmd.AddCommand(chatsCmd)
 }
 
 func runChats(cmd *cobra.Command, args []string) error {
-	client := graph.NewClient()
-	chats, err := client.ListChats(cmd.Context())
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
-
-	if chatsJSON {
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		return enc.Encode(chats)
+	var chats []graph.Chat
+	if chatsUser != "" {
+		for chat, err := range client.UserChats(cmd.Context(), chatsUser, graph.PageOptions{Top: 50}) {
+			if err != nil {
+				return err
+			}
+			chats = append(chats, chat)
+		}
+	} else {
+		chats, err = client.ListChats(cmd.Context())
+		if err != nil {
+			return err
+		}
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-	fmt.Fprintln(w, "CHAT ID\tTYPE\tNAME")
-	for _, chat := range chats {
-		name := graph.ChatDisplayName(chat)
-		fmt.Fprintf(w, "%s\t%s\t%s\n", chat.ID, chat.ChatType, name)
+	if chatsJSON {
+		outputFormat = output.FormatJSON
 	}
-	return w.Flush()
+	return printItems(cmd, chats, chatColumns)
 }
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/piotrwolkowski/tcli/config"
+	"github.com/piotrwolkowski/tcli/internal/auth"
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/httplog"
+	"github.com/piotrwolkowski/tcli/internal/recorder"
+)
+
+// newClient returns a Graph client for the signed-in user, or for the app
+// when client credentials are configured, honouring the cloud, Graph URL
+// override and rate limits in the configuration and the --record, --replay
+// and --debug flags.
+func newClient() (*graph.Client, error) {
+	opts := []graph.Option{graph.WithLogger(logger)}
+	// Missing credentials are reported when a token is needed, so replays
+	// work without any configuration.
+	cfg, err := config.Load()
+	if cfg == nil {
+		return nil, err
+	}
+	opts = append(opts, graph.WithBaseURL(cfg.GraphBaseURL()), graph.WithRateLimit(rateLimit(cfg)))
+	if cfg.AppOnly() {
+		opts = append(opts, graph.WithAppOnly())
+		if replayDir == "" {
+			ts, err := auth.NewAppTokenSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, graph.WithTokenSource(ts))
+		}
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	switch {
+	case recordDir != "":
+		rec, err := recorder.NewRecorder(recordDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		transport = rec
+	case replayDir != "":
+		rep, err := recorder.NewReplayer(replayDir)
+		if err != nil {
+			return nil, err
+		}
+		transport = rep
+		// Replays need no sign-in: recorded tokens are redacted anyway.
+		// Recorded retries are replayed immediately.
+		opts = append(opts,
+			graph.WithRetryPolicy(graph.RetryPolicy{MaxAttempts: graph.DefaultRetryPolicy.MaxAttempts}),
+			graph.WithRateLimit(graph.RateLimit{}),
+			graph.WithTokenSource(graph.TokenSourceFunc(func(context.Context) (string, error) {
+				return recorder.Redacted, nil
+			})),
+		)
+	}
+	if debugEnabled() {
+		transport = httplog.NewTransport(transport, os.Stderr)
+	}
+	opts = append(opts, graph.WithHTTPClient(&http.Client{Transport: transport}))
+
+	return graph.NewClient(opts...), nil
+}
+
+// requireUser fails with graph.ErrUserRequired when client credentials are
+// configured, for commands that can only act as a signed-in user and would
+// otherwise queue messages or start up before failing.
+func requireUser(op string) error {
+	if cfg, err := config.Load(); err == nil && cfg.AppOnly() {
+		return fmt.Errorf("%s: %w", op, graph.ErrUserRequired)
+	}
+	return nil
+}
+
+// rateLimit applies the configured limits to graph.DefaultRateLimit. Zero
+// keeps a default and a negative value turns that limit off.
+func rateLimit(cfg *config.Config) graph.RateLimit {
+	l := graph.DefaultRateLimit
+	if cfg.RateLimit != 0 {
+		l.Rate = max(cfg.RateLimit, 0)
+	}
+	if cfg.RateBurst != 0 {
+		l.Burst = max(cfg.RateBurst, 0)
+	}
+	if cfg.MaxInFlight != 0 {
+		l.MaxInFlight = max(cfg.MaxInFlight, 0)
+	}
+	return l
+}
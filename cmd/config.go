@@ -12,7 +12,7 @@ import (
 
 var configCmd = &cobra.Command{
 	Use:   "config",
-	Short: "Configure Azure app credentials (client ID and tenant ID)",
+	Short: "Configure Azure app credentials (client ID, tenant ID and cloud)",
 	RunE:  runConfig,
 }
 
@@ -21,26 +21,41 @@ func init() {
 }
 
 func runConfig(cmd *cobra.Command, args []string) error {
-	existing, _ := config.Load()
-	if existing == nil {
-		existing = &config.Config{}
+	// Only the file is updated: settings that come from the environment,
+	// such as TCLI_GRAPH_URL, must not be saved with it.
+	cfg, _ := config.LoadFile()
+	if cfg == nil {
+		cfg = &config.Config{}
 	}
 
 	reader := bufio.NewReader(os.Stdin)
 
-	clientID, err := prompt(reader, "Client ID", existing.ClientID)
+	clientID, err := prompt(reader, "Client ID", cfg.ClientID)
 	if err != nil {
 		return err
 	}
-	tenantID, err := prompt(reader, "Tenant ID", existing.TenantID)
+	tenantID, err := prompt(reader, "Tenant ID", cfg.TenantID)
 	if err != nil {
 		return err
 	}
-
-	cfg := &config.Config{
-		ClientID: clientID,
-		TenantID: tenantID,
+	cloud := cfg.Cloud
+	if cloud == "" {
+		cloud = config.CloudGlobal
+	}
+	cloud, err = prompt(reader, "Cloud ("+strings.Join(config.Clouds, ", ")+")", cloud)
+	if err != nil {
+		return err
 	}
+	if err := config.ValidateCloud(cloud); err != nil {
+		return usageError{err}
+	}
+	if cloud == config.CloudGlobal {
+		cloud = ""
+	}
+
+	cfg.ClientID = clientID
+	cfg.TenantID = tenantID
+	cfg.Cloud = cloud
 
 	if err := config.Save(cfg); err != nil {
 		return fmt.Errorf("saving config: %w", err)
@@ -0,0 +1,525 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/config"
+	"github.com/piotrwolkowski/tcli/graphtest"
+	"github.com/piotrwolkowski/tcli/internal/auth"
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/spool"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// setup starts a fake Graph, points tcli at it from a temporary home
+// directory and signs in with a token issued by the fake.
+func setup(t *testing.T) *graphtest.Server {
+	t.Helper()
+	srv := graphtest.NewServer(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TCLI_CLIENT_ID", "client")
+	t.Setenv("TCLI_TENANT_ID", "tenant")
+	t.Setenv("TCLI_AUTHORITY_URL", srv.AuthorityURL())
+	t.Setenv("TCLI_GRAPH_URL", srv.GraphURL())
+	t.Setenv("TCLI_OUTBOX", "")
+	t.Setenv("TCLI_CLIENT_SECRET", "")
+	t.Setenv("TCLI_CLIENT_SECRET_FILE", "")
+	t.Setenv("TCLI_CLIENT_CERTIFICATE", "")
+	t.Setenv("TCLI_FEDERATED_TOKEN", "")
+	t.Setenv("TCLI_FEDERATED_TOKEN_FILE", "")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+
+	// The token holds the permissions asked for on first use too.
+	cache := &auth.TokenCache{AccessToken: srv.IssueToken(), ExpiresAt: time.Now().Add(time.Hour),
+		Permissions: []string{"Chat.Read", "ChatMessage.Send", "Chat.ReadWrite", "Files.ReadWrite"}}
+	if err := auth.SaveCache(cache); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// run executes tcli with args and returns everything written to stdout.
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	resetFlags(rootCmd)
+
+	// Some commands print with fmt directly, so capture os.Stdout as well
+	// as the command's own writer.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	captured := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		captured <- string(data)
+	}()
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetErr(io.Discard)
+	rootCmd.SetArgs(args)
+	err = execute(context.Background())
+
+	w.Close()
+	os.Stdout = stdout
+	return <-captured + buf.String(), err
+}
+
+// resetFlags restores every flag to its default, since cobra keeps flag
+// values in package variables between executions.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+func TestLoginCommand(t *testing.T) {
+	srv := setup(t)
+	auth.ClearCache()
+	srv.AddChat(graphtest.Chat{ID: "chat", Topic: "Standup"})
+	srv.RestrictPermissions("Chat.Read", "ChatMessage.Send")
+
+	if out, err := run(t, "login"); err != nil || !strings.Contains(out, "Login successful") {
+		t.Fatalf("tcli login = %q, %v", out, err)
+	}
+	if out, err := run(t, "chats"); err != nil || !strings.Contains(out, "Standup") {
+		t.Errorf("chats after login = %q, %v", out, err)
+	}
+
+	// Editing needs Chat.ReadWrite, which this tenant has not granted.
+	if _, err := run(t, "send", "chat", "v1", "--idempotency-key", "k"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := run(t, "send", "chat", "v2", "--idempotency-key", "k", "--edit-on-change")
+	if ExitCode(err) != ExitPermission || !strings.Contains(fmt.Sprint(err), "Chat.ReadWrite") {
+		t.Errorf("edit without Chat.ReadWrite = %v (exit %d), want exit %d", err, ExitCode(err), ExitPermission)
+	}
+}
+
+func TestConfigSavesOnlyFileSettings(t *testing.T) {
+	setup(t)
+	t.Setenv("TCLI_RATE_LIMIT", "2")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "/var/run/token")
+	if err := config.Save(&config.Config{ClientID: "old", TenantID: "tenant", MaxInFlight: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	input := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(input, []byte("client\n\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	saved := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = saved }()
+
+	if _, err := run(t, "config"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config.Config{ClientID: "client", TenantID: "tenant", MaxInFlight: 2}
+	if *cfg != want {
+		t.Errorf("config.json = %+v, want %+v without the environment overrides", *cfg, want)
+	}
+}
+
+func TestChatsCommand(t *testing.T) {
+	srv := setup(t)
+	srv.SetPageSize(1)
+	srv.AddChat(graphtest.Chat{ID: "19:a@thread.v2", Topic: "Release", Members: []graphtest.Member{{DisplayName: "Alice"}}})
+	srv.AddChat(graphtest.Chat{ID: "19:b@thread.v2", ChatType: "oneOnOne", Members: []graphtest.Member{{DisplayName: "Bob"}}})
+
+	out, err := run(t, "chats", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chats []map[string]any
+	if err := json.Unmarshal([]byte(out), &chats); err != nil {
+		t.Fatalf("chats -o json output is not JSON: %v\n%s", err, out)
+	}
+	if len(chats) != 2 {
+		t.Errorf("got %d chats, want 2:\n%s", len(chats), out)
+	}
+
+	out, err = run(t, "chats", "--columns", "name", "--no-headers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(out), []string{"Release", "Bob"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("chats --columns name = %q, want %q", got, want)
+	}
+}
+
+func TestSendCommand(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+
+	out, err := run(t, "send", "chat", "Build passed", "--importance", "high", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Messages("chat")
+	if len(msgs) != 1 || msgs[0].Content != "Build passed" || msgs[0].Importance != "high" {
+		t.Fatalf("chat holds %+v, want the sent message", msgs)
+	}
+	if !strings.Contains(out, msgs[0].ID) {
+		t.Errorf("send output %q does not include message ID %s", out, msgs[0].ID)
+	}
+
+	if _, err := run(t, "send", "missing", "hello"); err == nil || !strings.Contains(err.Error(), "NotFound") {
+		t.Errorf("send to unknown chat = %v, want NotFound", err)
+	}
+}
+
+func TestSendIdempotencyKey(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+
+	for range 2 {
+		if _, err := run(t, "send", "chat", "Deployed v1", "--idempotency-key", "deploy-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if msgs := srv.Messages("chat"); len(msgs) != 1 {
+		t.Fatalf("chat holds %d messages after a repeated send, want 1", len(msgs))
+	}
+
+	if _, err := run(t, "send", "chat", "Deployed v1 (fixed)", "--idempotency-key", "deploy-1", "--edit-on-change"); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Messages("chat")
+	if len(msgs) != 1 || msgs[0].Content != "Deployed v1 (fixed)" || msgs[0].EditedAt.IsZero() {
+		t.Errorf("chat holds %+v, want the original message edited", msgs)
+	}
+}
+
+func TestSendOutbox(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	srv.Fail(graphtest.Fault{Method: "POST", Path: "/me/chats", Status: 500, Code: "InternalServerError"})
+
+	if _, err := run(t, "send", "chat", "hello", "--outbox"); err != nil {
+		t.Fatalf("send --outbox on a server error = %v, want it queued", err)
+	}
+	if n := len(srv.Messages("chat")); n != 0 {
+		t.Fatalf("chat holds %d messages, want 0 before flushing", n)
+	}
+
+	out, err := run(t, "outbox", "list", "--columns", "chat", "--no-headers")
+	if err != nil || strings.TrimSpace(out) != "chat" {
+		t.Fatalf("outbox list = %q, %v; want the queued message", out, err)
+	}
+
+	out, err = run(t, "outbox", "drop", "--all", "-o", "json")
+	var dropped []spool.Item
+	if err != nil || json.Unmarshal([]byte(out), &dropped) != nil || len(dropped) != 1 || dropped[0].ChatID != "chat" {
+		t.Errorf("outbox drop --all -o json = %q, %v; want the dropped message", out, err)
+	}
+}
+
+func TestScheduleRunAndCancel(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	q, err := openQueue("schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := graph.SendMessageRequest{Body: graph.MessageBody{Content: "standup"}}
+	if _, err := q.Add(spool.Item{ChatID: "chat", Message: msg, DueAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	later, err := q.Add(spool.Item{ChatID: "chat", Message: msg, DueAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := run(t, "schedule", "run", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delivered []deliveryResult
+	if err := json.Unmarshal([]byte(out), &delivered); err != nil || len(delivered) != 1 ||
+		delivered[0].Status != "sent" || delivered[0].MessageID == "" {
+		t.Errorf("schedule run -o json = %q, %v; want one sent message", out, err)
+	}
+
+	out, err = run(t, "schedule", "cancel", later.ID, "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cancelled []spool.Item
+	if err := json.Unmarshal([]byte(out), &cancelled); err != nil || len(cancelled) != 1 || cancelled[0].ID != later.ID {
+		t.Errorf("schedule cancel -o json = %q, %v; want the cancelled message", out, err)
+	}
+}
+
+func TestSignInOutage(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	auth.SaveCache(&auth.TokenCache{AccessToken: "old", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour)})
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	t.Setenv("TCLI_AUTHORITY_URL", down.URL)
+
+	if _, err := run(t, "send", "chat", "hello"); ExitCode(err) != ExitTransient {
+		t.Errorf("send with the sign-in service down = %v (exit %d), want exit %d", err, ExitCode(err), ExitTransient)
+	}
+	if _, err := run(t, "send", "chat", "hello", "--outbox"); err != nil {
+		t.Fatalf("send --outbox with the sign-in service down = %v, want it queued", err)
+	}
+	out, err := run(t, "outbox", "list", "--columns", "chat", "--no-headers")
+	if err != nil || strings.TrimSpace(out) != "chat" {
+		t.Errorf("outbox list = %q, %v; want the queued message", out, err)
+	}
+}
+
+func TestReactCommand(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	srv.AddMessages("chat", graphtest.Message{ID: "m1", From: "Alice", Content: "ship it?"})
+
+	out, err := run(t, "react", "chat", "m1", "like", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []reactionResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil || len(results) != 1 || results[0].Status != "added" {
+		t.Errorf("react -o json = %q, %v; want the added reaction", out, err)
+	}
+	if got := srv.Messages("chat")[0].Reactions; len(got) != 1 || got[0] != "like" {
+		t.Errorf("reactions = %v, want [like]", got)
+	}
+	if _, err := run(t, "unreact", "chat", "m1", "like"); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Messages("chat")[0].Reactions; len(got) != 0 {
+		t.Errorf("reactions after unreact = %v, want none", got)
+	}
+}
+
+func TestNotLoggedIn(t *testing.T) {
+	setup(t)
+	auth.ClearCache()
+
+	if _, err := run(t, "chats"); err == nil || !strings.Contains(err.Error(), "tcli login") {
+		t.Errorf("chats without login = %v, want a login hint", err)
+	}
+}
+
+func TestAppOnly(t *testing.T) {
+	srv := setup(t)
+	auth.ClearCache()
+	t.Setenv("TCLI_CLIENT_SECRET", graphtest.ClientSecret)
+	srv.AddChat(graphtest.Chat{ID: "chat", Topic: "Builds"})
+	srv.AddMessages("chat", graphtest.Message{From: "Alice", Content: "deployed"})
+
+	out, err := run(t, "login")
+	if err != nil || !strings.Contains(out, "App credentials accepted") {
+		t.Fatalf("login = %q, %v", out, err)
+	}
+
+	out, err = run(t, "export", "chat", "--format", "json")
+	if err != nil || !strings.Contains(out, "deployed") {
+		t.Errorf("export = %q, %v; want the chat history", out, err)
+	}
+	out, err = run(t, "chats", "--user", "someone@example.com", "-o", "json")
+	if err != nil || !strings.Contains(out, "Builds") {
+		t.Errorf("chats --user = %q, %v; want the user's chats", out, err)
+	}
+
+	before := len(srv.Requests())
+	for _, args := range [][]string{
+		{"send", "chat", "hello"},
+		{"chats"},
+		{"react", "chat", "1", "👍"},
+		{"search", "deployed"},
+		{"send", "chat", "later", "--in", "1s"},
+		{"schedule", "run"},
+		{"outbox", "flush"},
+		{"chat", "open", "chat"},
+		{"ui"},
+	} {
+		_, err := run(t, args...)
+		if ExitCode(err) != ExitAuth || !strings.Contains(fmt.Sprint(err), "app-only") {
+			t.Errorf("%v in app-only mode = %v, want a fast app-only error", args, err)
+		}
+	}
+	if out, err := run(t, "schedule", "list", "-o", "json"); err != nil || strings.Contains(out, "later") {
+		t.Errorf("schedule list = %q, %v; want nothing queued in app-only mode", out, err)
+	}
+	// Only token requests, no Graph calls, for the unsupported commands.
+	for _, r := range srv.Requests()[before:] {
+		if strings.HasPrefix(r.Path, "/v1.0/") {
+			t.Errorf("unsupported command called Graph: %s %s", r.Method, r.Path)
+		}
+	}
+
+	t.Setenv("TCLI_CLIENT_SECRET", "wrong")
+	if _, err := run(t, "export", "chat"); ExitCode(err) != ExitAuth {
+		t.Errorf("export with a wrong secret = %v, want exit code %d", err, ExitAuth)
+	}
+}
+
+func TestFederatedToken(t *testing.T) {
+	srv := setup(t)
+	auth.ClearCache()
+	srv.AddChat(graphtest.Chat{ID: "chat", Topic: "Builds"})
+	file := filepath.Join(t.TempDir(), "oidc-token")
+	os.WriteFile(file, []byte("header.payload.signature"), 0600)
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", file)
+
+	out, err := run(t, "chats", "--user", "someone@example.com")
+	if err != nil || !strings.Contains(out, "Builds") {
+		t.Errorf("chats --user with a federated token = %q, %v", out, err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat", Topic: "Incidents"})
+	dir := t.TempDir()
+
+	recorded, err := run(t, "chats", "--record", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay works signed out and with Graph unreachable.
+	auth.ClearCache()
+	t.Setenv("TCLI_GRAPH_URL", "http://127.0.0.1:1/v1.0")
+	replayed, err := run(t, "chats", "--replay", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != recorded || !strings.Contains(replayed, "Incidents") {
+		t.Errorf("replayed output %q, want %q", replayed, recorded)
+	}
+}
+
+func TestJSONLogFile(t *testing.T) {
+	srv := setup(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	srv.Throttle(1)
+	logPath := t.TempDir() + "/tcli.log"
+
+	if _, err := run(t, "chats", "--log-level", "debug", "--log-format", "json", "--log-file", logPath); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		msgs = append(msgs, entry["msg"].(string))
+	}
+	for _, want := range []string{"throttled by Graph, waiting", "fetched page"} {
+		if !slices.Contains(msgs, want) {
+			t.Errorf("log has %q, want an entry %q", msgs, want)
+		}
+	}
+
+	if _, err := run(t, "chats", "--log-format", "xml"); err == nil {
+		t.Error("--log-format xml was accepted")
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(srv *graphtest.Server)
+		args  []string
+		want  int
+	}{
+		{name: "success", args: []string{"chats"}, want: ExitOK},
+		{name: "unknown flag", args: []string{"chats", "--bogus"}, want: ExitUsage},
+		{name: "too many args", args: []string{"send", "a", "b", "c"}, want: ExitUsage},
+		{name: "unknown command", args: []string{"bogus"}, want: ExitUsage},
+		{name: "invalid importance", args: []string{"send", "chat", "hi", "--importance", "meh"}, want: ExitUsage},
+		{name: "invalid export format", args: []string{"export", "chat", "--format", "pdf"}, want: ExitUsage},
+		{name: "invalid export date", args: []string{"export", "chat", "--since", "yesterday"}, want: ExitUsage},
+		{name: "invalid search date", args: []string{"search", "hi", "--until", "tomorrow"}, want: ExitUsage},
+		{name: "outbox drop without ID", args: []string{"outbox", "drop"}, want: ExitUsage},
+		{name: "chat not found", args: []string{"send", "missing", "hi"}, want: ExitNotFound},
+		{
+			name: "permission denied",
+			setup: func(srv *graphtest.Server) {
+				srv.Fail(graphtest.Fault{Path: "/me/chats", Status: 403, Code: "Forbidden"})
+			},
+			args: []string{"chats"},
+			want: ExitPermission,
+		},
+		{
+			name:  "throttled",
+			setup: func(srv *graphtest.Server) { srv.Throttle(10) },
+			args:  []string{"chats"},
+			want:  ExitTransient,
+		},
+		{
+			name:  "token rejected",
+			setup: func(srv *graphtest.Server) { srv.ExpireAccessTokens() },
+			args:  []string{"chats"},
+			want:  ExitAuth,
+		},
+		{
+			name:  "not logged in",
+			setup: func(srv *graphtest.Server) { auth.ClearCache() },
+			args:  []string{"chats"},
+			want:  ExitAuth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setup(t)
+			srv.AddChat(graphtest.Chat{ID: "chat"})
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+			_, err := run(t, tt.args...)
+			if got := ExitCode(err); got != tt.want {
+				t.Errorf("exit code = %d (error: %v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/piotrwolkowski/tcli/internal/auth"
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/spf13/cobra"
+)
+
+// Process exit codes, documented in the README. Scripts can rely on them.
+const (
+	ExitOK         = 0
+	ExitError      = 1 // any other failure
+	ExitUsage      = 2 // invalid command, arguments or flags
+	ExitAuth       = 3 // not logged in, session expired or app credentials unusable
+	ExitPermission = 4 // Graph refused access (403) or a permission was not granted
+	ExitNotFound   = 5 // chat or message not found (404)
+	ExitTransient  = 6 // throttled, Graph unavailable or network error; retry later
+)
+
+// usageError marks errors caused by how tcli was invoked.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// ExitCode maps an error returned by Execute to the process exit code.
+func ExitCode(err error) int {
+	var usage usageError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &usage), isCobraUsageError(err):
+		return ExitUsage
+	case errors.Is(err, auth.ErrNotLoggedIn), errors.Is(err, auth.ErrSessionExpired),
+		errors.Is(err, auth.ErrCredentialsRejected), errors.Is(err, graph.ErrUserRequired),
+		graph.IsStatus(err, http.StatusUnauthorized):
+		return ExitAuth
+	case graph.IsStatus(err, http.StatusForbidden), errors.Is(err, auth.ErrConsentRequired):
+		return ExitPermission
+	case graph.IsStatus(err, http.StatusNotFound):
+		return ExitNotFound
+	case graph.IsTransient(err):
+		return ExitTransient
+	}
+	return ExitError
+}
+
+// isCobraUsageError recognises the usage errors cobra reports as plain
+// strings, which cannot be wrapped on the way out.
+func isCobraUsageError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "unknown command") || strings.HasPrefix(msg, "required flag(s)")
+}
+
+var markUsageOnce sync.Once
+
+// markUsageErrors wraps flag and argument validation errors of every command
+// in usageError. It must run after all commands have been registered and
+// before flags are parsed; later calls do nothing.
+func markUsageErrors() {
+	markUsageOnce.Do(func() {
+		rootCmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+			return usageError{err}
+		})
+		var walk func(c *cobra.Command)
+		walk = func(c *cobra.Command) {
+			if args := c.Args; args != nil {
+				c.Args = func(c *cobra.Command, a []string) error {
+					if err := args(c, a); err != nil {
+						return usageError{err}
+					}
+					return nil
+				}
+			}
+			for _, sub := range c.Commands() {
+				walk(sub)
+			}
+		}
+		walk(rootCmd)
+	})
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/export"
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat string
+	exportSince  string
+	exportUntil  string
+	exportFile   string
+	exportFresh  bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <chat-id>",
+	Short: "Export a chat's message history",
+	Long: `Export the full message history of a chat as JSON, Markdown, HTML or mbox,
+including sender names, timestamps, edits, replies and attachment links.
+
+When writing to a file, progress is journalled to <file>.partial after every
+page. If the export is interrupted, running the same command again resumes
+from the last page fetched; use --restart to start over.
+
+Examples:
+  tcli export 19:abc123@thread.v2 --format markdown -o incident.md
+  tcli export 19:abc123@thread.v2 --since 2026-10-01 --until 2026-10-08 -o week.json
+  tcli export 19:abc123@thread.v2 --format mbox > chat.mbox`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", export.FormatJSON, "export format: "+strings.Join(export.Formats, ", "))
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "only export messages created after this date/time")
+	exportCmd.Flags().StringVar(&exportUntil, "until", "", "only export messages created before this date/time")
+	// Shadows the global --output flag; export has its own --format.
+	exportCmd.Flags().StringVarP(&exportFile, "output", "o", "", "write to this file instead of stdout")
+	exportCmd.Flags().BoolVar(&exportFresh, "restart", false, "ignore any interrupted export and start over")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	chatID := args[0]
+
+	if err := export.ValidateFormat(exportFormat); err != nil {
+		return usageError{err}
+	}
+
+	var q graph.MessageQuery
+	if exportSince != "" {
+		t, err := parseTimeFlag("since", exportSince)
+		if err != nil {
+			return usageError{err}
+		}
+		q.After = t
+	}
+	if exportUntil != "" {
+		t, err := parseTimeFlag("until", exportUntil)
+		if err != nil {
+			return usageError{err}
+		}
+		q.Before = t
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	chat, err := client.GetChat(cmd.Context(), chatID)
+	if err != nil {
+		return err
+	}
+
+	var state export.JournalState
+	var journal *export.Journal
+	if exportFile != "" {
+		partial := exportFile + ".partial"
+		if exportFresh {
+			os.Remove(partial)
+		}
+		journal, state, err = export.OpenJournal(partial, chatID, q.After, q.Before)
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+		if state.Resumed {
+			fmt.Fprintf(os.Stderr, "Resuming interrupted export (%d messages already fetched)\n", len(state.Messages))
+		}
+	}
+
+	msgs := state.Messages
+	next := state.Next
+	for !state.Done {
+		page, nextLink, err := client.MessagesPage(cmd.Context(), chatID, q, next)
+		if err != nil {
+			if journal != nil {
+				return fmt.Errorf("%w (progress saved — rerun the same command to resume)", err)
+			}
+			return err
+		}
+		msgs = append(msgs, page...)
+		if journal != nil {
+			if err := journal.Append(page, nextLink); err != nil {
+				return err
+			}
+		}
+		if exportFile != "" {
+			fmt.Fprintf(os.Stderr, "\rFetched %d messages", len(msgs))
+		}
+		if nextLink == "" {
+			break
+		}
+		next = nextLink
+	}
+	if exportFile != "" {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	msgs = oldestFirst(msgs)
+	meta := export.Meta{
+		ChatID:     chatID,
+		ChatName:   graph.ChatDisplayName(*chat),
+		ExportedAt: time.Now(),
+		Since:      q.After,
+		Until:      q.Before,
+	}
+
+	if exportFile == "" {
+		return export.Write(cmd.OutOrStdout(), exportFormat, meta, msgs)
+	}
+
+	// Write to a temporary file first so a failed render never leaves a
+	// truncated export behind.
+	tmp, err := os.CreateTemp(filepath.Dir(exportFile), filepath.Base(exportFile)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := export.Write(tmp, exportFormat, meta, msgs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exportFile); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	journal.Remove()
+
+	fmt.Fprintf(os.Stderr, "Exported %d messages to %s\n", len(msgs), exportFile)
+	return nil
+}
+
+// oldestFirst sorts messages by creation time and drops duplicates, which can
+// appear when a resumed export refetches a page.
+func oldestFirst(msgs []graph.Message) []graph.Message {
+	seen := make(map[string]bool, len(msgs))
+	out := make([]graph.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if seen[m.ID] {
+			continue
+		}
+		seen[m.ID] = true
+		out = append(out, m)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].CreatedAt < out[j].CreatedAt
+	})
+	return out
+}
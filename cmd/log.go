@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log formats accepted by --log-format.
+var logFormats = []string{"text", "json"}
+
+var (
+	logLevel  string
+	logFormat string
+	logFile   string
+
+	// logger is configured from the log flags before each command runs.
+	logger  = slog.New(slog.DiscardHandler)
+	logSink io.Closer
+)
+
+// setupLogging builds the logger selected by --log-level, --log-format and
+// --log-file and installs it as the default and in the auth package.
+func setupLogging() (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return nil, usageError{fmt.Errorf("invalid --log-level %q — use debug, info, warn or error", logLevel)}
+	}
+
+	var w io.Writer = os.Stderr
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("opening log file: %w", err)
+		}
+		w, logSink = f, f
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch logFormat {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, usageError{fmt.Errorf("invalid --log-format %q — use %s", logFormat, strings.Join(logFormats, " or "))}
+	}
+	return slog.New(h), nil
+}
+
+// closeLog closes the --log-file, if one was opened.
+func closeLog() {
+	if logSink != nil {
+		logSink.Close()
+		logSink = nil
+	}
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+
+	"github.com/piotrwolkowski/tcli/config"
 	"github.com/piotrwolkowski/tcli/internal/auth"
 	"github.com/spf13/cobra"
 )
@@ -8,11 +11,39 @@ import (
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate with Microsoft Teams using device code flow",
+	Long: `Authenticate with Microsoft Teams using device code flow.
+
+When app credentials are configured (TCLI_CLIENT_SECRET, TCLI_CLIENT_SECRET_FILE
+or TCLI_CLIENT_CERTIFICATE), tcli signs in as the app on every run instead, and
+login only checks that the credentials are accepted.
+
+tcli asks for Chat.Read and ChatMessage.Send at sign-in, and for
+Chat.ReadWrite or Files.ReadWrite the first time a message is edited or a file
+attached. Use --permission to grant one of those during sign-in instead, e.g.
+when the tenant needs you to consent interactively.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return auth.Login(cmd.Context())
+		if cfg, err := config.Load(); err == nil && cfg.AppOnly() {
+			return checkAppCredentials(cmd, cfg)
+		}
+		return auth.Login(cmd.Context(), loginPermissions...)
 	},
 }
 
+var loginPermissions []string
+
 func init() {
+	loginCmd.Flags().StringSliceVar(&loginPermissions, "permission", nil, "additional Graph permission to ask for, e.g. Files.ReadWrite (repeatable)")
 	rootCmd.AddCommand(loginCmd)
 }
+
+func checkAppCredentials(cmd *cobra.Command, cfg *config.Config) error {
+	ts, err := auth.NewAppTokenSource(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := ts.Token(cmd.Context()); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "App credentials accepted. tcli runs with app-only authentication; see the README for the commands it supports.")
+	return nil
+}
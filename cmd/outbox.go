@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/spool"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outboxWatch    bool
+	outboxInterval time.Duration
+	outboxDropAll  bool
+)
+
+var outboxCmd = &cobra.Command{
+	Use:   "outbox",
+	Short: "Inspect and retry messages that failed to send",
+	Long: `With tcli send --outbox (or TCLI_OUTBOX=1), messages that fail to send
+because of a network error, throttling or a Graph server error are saved to a
+local outbox instead of being lost. "tcli outbox flush" retries them with
+exponential backoff; run it from cron or keep "tcli outbox flush --watch"
+running in the background.`,
+}
+
+var outboxListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List messages waiting in the outbox",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := openQueue("outbox")
+		if err != nil {
+			return err
+		}
+		items, err := q.List()
+		if err != nil {
+			return err
+		}
+		return printItems(cmd, items, queueColumns)
+	},
+}
+
+var outboxDropCmd = &cobra.Command{
+	Use:   "drop [id]",
+	Short: "Remove a message from the outbox without sending it",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 0) == !outboxDropAll {
+			return usageError{fmt.Errorf("give a message ID or --all")}
+		}
+		q, err := openQueue("outbox")
+		if err != nil {
+			return err
+		}
+		if !outboxDropAll {
+			item, err := q.Remove(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Dropped %s.\n", item.ID)
+			return printItems(cmd, []spool.Item{*item}, queueColumns)
+		}
+		items, err := q.List()
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if _, err := q.Remove(item.ID); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Dropped %d message(s).\n", len(items))
+		return printItems(cmd, items, queueColumns)
+	},
+}
+
+var outboxFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Retry sending messages in the outbox",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := openQueue("outbox")
+		if err != nil {
+			return err
+		}
+		return deliverQueue(cmd, q, outboxWatch, outboxInterval)
+	},
+}
+
+func init() {
+	outboxDropCmd.Flags().BoolVar(&outboxDropAll, "all", false, "drop every message in the outbox")
+	outboxFlushCmd.Flags().BoolVar(&outboxWatch, "watch", false, "keep running and retry messages as their backoff expires")
+	outboxFlushCmd.Flags().DurationVar(&outboxInterval, "interval", 30*time.Second, "how often to check the outbox with --watch")
+	outboxCmd.AddCommand(outboxListCmd, outboxDropCmd, outboxFlushCmd)
+	rootCmd.AddCommand(outboxCmd)
+}
+
+// outboxEnabled reports whether failed sends should go to the outbox.
+func outboxEnabled(flag bool) bool {
+	return flag || envEnabled("TCLI_OUTBOX")
+}
+
+// queueFailedSend saves a message that failed with a transient error to the
+// outbox so it can be retried later. The item's dedupe key, if any, is kept
+// so the delivery is recorded like a direct send.
+func queueFailedSend(cmd *cobra.Command, item spool.Item, sendErr error) error {
+	q, err := openQueue("outbox")
+	if err != nil {
+		return err
+	}
+	item.Attempts = 1
+	item.LastError = sendErr.Error()
+	item.NextAttempt = time.Now().Add(spool.Backoff(1))
+	queued, err := q.Add(item)
+	if err != nil {
+		return fmt.Errorf("%w (and saving to the outbox failed: %v)", sendErr, err)
+	}
+	fmt.Fprintf(os.Stderr, "Send failed: %v\nQueued in the outbox as %s — it will be sent by: tcli outbox flush\n", sendErr, queued.ID)
+	return printItems(cmd, []spool.Item{*queued}, queueColumns)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/piotrwolkowski/tcli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// outputOptions returns the output settings selected by the global flags.
+func outputOptions() output.Options {
+	return output.Options{
+		Format:    outputFormat,
+		NoHeaders: noHeaders,
+		Columns:   columns,
+	}
+}
+
+// printItems renders items to the command's stdout using the global output flags.
+func printItems[T any](cmd *cobra.Command, items []T, cols []output.Column[T]) error {
+	return output.Print(cmd.OutOrStdout(), outputOptions(), items, cols)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/picker"
+	"github.com/piotrwolkowski/tcli/internal/tty"
+	"github.com/spf13/cobra"
+)
+
+// pickChat lets the user choose a chat interactively and returns its ID.
+// Chats are listed most recently active first.
+func pickChat(cmd *cobra.Command, client *graph.Client) (string, error) {
+	if !tty.IsTerminal(os.Stdin, os.Stdout) {
+		return "", fmt.Errorf("no chat ID given — pass one explicitly (interactive selection requires a terminal)")
+	}
+
+	chats, err := client.ListChats(cmd.Context())
+	if err != nil {
+		return "", err
+	}
+	sort.SliceStable(chats, func(i, j int) bool {
+		return chats[i].LastUpdated > chats[j].LastUpdated
+	})
+
+	items := make([]picker.Item, len(chats))
+	for i, chat := range chats {
+		items[i] = picker.Item{
+			Label: fmt.Sprintf("%-40.40s  %-9s  %s", graph.ChatDisplayName(chat), chat.ChatType, relativeTime(chat.LastUpdated)),
+			Value: chat.ID,
+		}
+	}
+
+	id, err := picker.Pick(os.Stdin, os.Stdout, "chat> ", items)
+	if errors.Is(err, picker.ErrCancelled) {
+		return "", fmt.Errorf("no chat selected")
+	}
+	return id, err
+}
+
+// relativeTime renders a Graph timestamp as a short age such as "5m ago".
+func relativeTime(ts string) string {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ""
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+	return t.Local().Format("2006-01-02")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/config"
+	"github.com/piotrwolkowski/tcli/internal/output"
+	"github.com/piotrwolkowski/tcli/internal/spool"
+	"github.com/spf13/cobra"
+)
+
+// Local message queues shared by tcli schedule and tcli outbox.
+
+var queueColumns = []output.Column[spool.Item]{
+	{Name: "id", Header: "ID", Value: func(i spool.Item) string { return i.ID }},
+	{Name: "due", Header: "DUE", Value: func(i spool.Item) string { return i.ReadyAt().Local().Format("2006-01-02 15:04") }},
+	{Name: "chat", Header: "CHAT ID", Value: func(i spool.Item) string { return i.ChatID }},
+	{Name: "message", Header: "MESSAGE", Value: func(i spool.Item) string { return preview(i.Message.Body.Content, 40) }},
+	{Name: "status", Header: "STATUS", Value: queueStatus},
+}
+
+// deliveryResult is one line of schedule run and outbox flush output.
+type deliveryResult struct {
+	ID     string `json:"id"`
+	ChatID string `json:"chatId"`
+	// Status is sent, duplicate (dropped as already sent), retrying or failed.
+	Status      string    `json:"status"`
+	MessageID   string    `json:"messageId,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt,omitzero"`
+	Error       string    `json:"error,omitempty"`
+}
+
+var deliveryColumns = []output.Column[deliveryResult]{
+	{Name: "id", Header: "ID", Value: func(r deliveryResult) string { return r.ID }},
+	{Name: "chat", Header: "CHAT ID", Value: func(r deliveryResult) string { return r.ChatID }},
+	{Name: "status", Header: "STATUS", Value: func(r deliveryResult) string { return r.Status }},
+	{Name: "message", Header: "MESSAGE ID", Value: func(r deliveryResult) string { return r.MessageID }},
+	{Name: "next", Header: "NEXT ATTEMPT", Value: func(r deliveryResult) string {
+		if r.NextAttempt.IsZero() {
+			return ""
+		}
+		return r.NextAttempt.Local().Format("15:04:05")
+	}},
+	{Name: "error", Header: "ERROR", Value: func(r deliveryResult) string { return r.Error }},
+}
+
+func newDeliveryResult(r spool.Result) deliveryResult {
+	d := deliveryResult{ID: r.Item.ID, ChatID: r.Item.ChatID, MessageID: r.MessageID, Attempts: r.Item.Attempts}
+	switch {
+	case r.Err != nil && r.Item.Failed:
+		d.Status, d.Error = "failed", r.Err.Error()
+	case r.Err != nil:
+		d.Status, d.Error, d.NextAttempt = "retrying", r.Err.Error(), r.Item.NextAttempt
+	case r.Duplicate:
+		d.Status = "duplicate"
+	default:
+		d.Status = "sent"
+	}
+	return d
+}
+
+// openQueue returns the queue stored in the named directory under config.Dir().
+func openQueue(name string) (*spool.Queue, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	q := spool.NewQueue(filepath.Join(dir, name))
+	if q.Sent, err = sentStore(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// deliverQueue sends everything ready in q, printing each result. With
+// watch it keeps checking every interval until the command is cancelled.
+// Ctrl-C stops delivery cleanly, leaving unsent messages queued.
+func deliverQueue(cmd *cobra.Command, q *spool.Queue, watch bool, interval time.Duration) error {
+	if err := requireUser("sending messages"); err != nil {
+		return err
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	for {
+		results, err := q.RunDue(ctx, client, time.Now())
+		failed := 0
+		delivered := make([]deliveryResult, len(results))
+		for i, r := range results {
+			if r.Err != nil {
+				failed++
+			}
+			if r.RecordErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s sent but not recorded for deduplication: %v\n", r.Item.ID, r.RecordErr)
+			}
+			delivered[i] = newDeliveryResult(r)
+		}
+		if len(delivered) > 0 {
+			if perr := printItems(cmd, delivered, deliveryColumns); perr != nil {
+				return perr
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintln(os.Stderr, "Interrupted; unsent messages stay queued.")
+				if watch {
+					return nil
+				}
+			}
+			return err
+		}
+
+		if !watch {
+			if failed > 0 {
+				return fmt.Errorf("%d message(s) failed to send", failed)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func queueStatus(i spool.Item) string {
+	switch {
+	case i.Sending:
+		return "sending"
+	case i.Failed:
+		return fmt.Sprintf("failed: %s", i.LastError)
+	case i.LastError != "":
+		return fmt.Sprintf("retrying (%d failed: %s)", i.Attempts, i.LastError)
+	}
+	return "pending"
+}
+
+// preview shortens a message to a single line of at most n runes.
+func preview(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"github.com/piotrwolkowski/tcli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// reactionResult is what react and unreact print.
+type reactionResult struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Reaction  string `json:"reaction"`
+	Status    string `json:"status"` // added or removed
+}
+
+var reactionColumns = []output.Column[reactionResult]{
+	{Name: "chat", Header: "CHAT ID", Value: func(r reactionResult) string { return r.ChatID }},
+	{Name: "message", Header: "MESSAGE ID", Value: func(r reactionResult) string { return r.MessageID }},
+	{Name: "reaction", Header: "REACTION", Value: func(r reactionResult) string { return r.Reaction }},
+	{Name: "status", Header: "STATUS", Value: func(r reactionResult) string { return r.Status }},
+}
+
+var reactCmd = &cobra.Command{
+	Use:   "react <chat-id> <message-id> <reaction>",
+	Short: "React to a message",
+	Long: `Add your reaction to a message. The reaction is any emoji, or one of the
+classic names: like, heart, laugh, surprised, sad, angry.
+
+Examples:
+  tcli react 19:abc123@thread.v2 1700000000000 ✅
+  tcli react 19:abc123@thread.v2 1700000000000 like`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		if err := client.SetReaction(cmd.Context(), args[0], args[1], args[2]); err != nil {
+			return err
+		}
+		return printItems(cmd, []reactionResult{{ChatID: args[0], MessageID: args[1], Reaction: args[2], Status: "added"}}, reactionColumns)
+	},
+}
+
+var unreactCmd = &cobra.Command{
+	Use:   "unreact <chat-id> <message-id> <reaction>",
+	Short: "Remove your reaction from a message",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		if err := client.UnsetReaction(cmd.Context(), args[0], args[1], args[2]); err != nil {
+			return err
+		}
+		return printItems(cmd, []reactionResult{{ChatID: args[0], MessageID: args[1], Reaction: args[2], Status: "removed"}}, reactionColumns)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reactCmd)
+	rootCmd.AddCommand(unreactCmd)
+}
@@ -1,15 +1,90 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/piotrwolkowski/tcli/internal/auth"
+	"github.com/piotrwolkowski/tcli/internal/httplog"
+	"github.com/piotrwolkowski/tcli/internal/output"
 	"github.com/spf13/cobra"
 )
 
+var (
+	outputFormat string
+	noHeaders    bool
+	columns      []string
+	recordDir    string
+	replayDir    string
+	debug        bool
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "tcli",
 	Short: "Microsoft Teams CLI client",
 	Long:  "A command-line client for Microsoft Teams. List chats, send messages, and pipe output — all from your terminal.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if recordDir != "" && replayDir != "" {
+			return usageError{fmt.Errorf("use either --record or --replay, not both")}
+		}
+		if err := output.Validate(outputFormat); err != nil {
+			return usageError{err}
+		}
+		auth.HTTPClient.Transport = nil
+		if debugEnabled() {
+			auth.HTTPClient.Transport = httplog.NewTransport(nil, os.Stderr)
+		}
+		l, err := setupLogging()
+		if err != nil {
+			return err
+		}
+		logger = l
+		slog.SetDefault(logger)
+		auth.Logger = logger
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		closeLog()
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", output.FormatTable, "output format: "+strings.Join(output.Formats, ", "))
+	rootCmd.PersistentFlags().BoolVar(&noHeaders, "no-headers", false, "omit the header row in table and CSV output")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "save Graph requests and responses to fixture files in this directory (tokens are redacted)")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "answer Graph requests from fixtures recorded with --record instead of the network")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "trace HTTP requests to stderr with secrets redacted (or set TCLI_DEBUG=1)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn", "log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format: "+strings.Join(logFormats, " or "))
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "write logs to this file instead of stderr")
+	rootCmd.PersistentFlags().StringSliceVar(&columns, "columns", nil, "comma-separated columns to show in table and CSV output")
+}
+
+// debugEnabled reports whether HTTP tracing was requested.
+func debugEnabled() bool {
+	return debug || envEnabled("TCLI_DEBUG")
+}
+
+// envEnabled reports whether a boolean environment variable is switched on.
+func envEnabled(name string) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	defer closeLog()
+	return execute(context.Background())
+}
+
+// execute runs the command line. Usage errors are marked here rather than in
+// an initializer, which cobra only runs after parsing flags.
+func execute(ctx context.Context) error {
+	markUsageErrors()
+	return rootCmd.ExecuteContext(ctx)
 }
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/spool"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleWatch    bool
+	scheduleInterval time.Duration
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage messages scheduled with send --at / --in",
+	Long: `Manage messages scheduled with tcli send --at or --in. Teams has no
+scheduled chat messages, so they are queued locally and delivered by
+"tcli schedule run" — run it from cron, or keep "tcli schedule run --watch"
+running in the background.`,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scheduled messages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := openQueue("schedule")
+		if err != nil {
+			return err
+		}
+		items, err := q.List()
+		if err != nil {
+			return err
+		}
+		return printItems(cmd, items, queueColumns)
+	},
+}
+
+var scheduleCancelCmd = &cobra.Command{
+	Use:   "cancel <id>",
+	Short: "Cancel a scheduled message",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := openQueue("schedule")
+		if err != nil {
+			return err
+		}
+		item, err := q.Remove(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Cancelled %s.\n", item.ID)
+		return printItems(cmd, []spool.Item{*item}, queueColumns)
+	},
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Send scheduled messages that are due",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := openQueue("schedule")
+		if err != nil {
+			return err
+		}
+		return deliverQueue(cmd, q, scheduleWatch, scheduleInterval)
+	},
+}
+
+func init() {
+	scheduleRunCmd.Flags().BoolVar(&scheduleWatch, "watch", false, "keep running and deliver messages as they fall due")
+	scheduleRunCmd.Flags().DurationVar(&scheduleInterval, "interval", 30*time.Second, "how often to check the queue with --watch")
+	scheduleCmd.AddCommand(scheduleListCmd, scheduleCancelCmd, scheduleRunCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
+
+// scheduleSend queues the message instead of sending it when --at or --in is set.
+func scheduleSend(cmd *cobra.Command, chatID string, msg graph.SendMessageRequest, due time.Time) error {
+	q, err := openQueue("schedule")
+	if err != nil {
+		return err
+	}
+	item, err := q.Add(spool.Item{ChatID: chatID, Message: msg, DueAt: due})
+	if err != nil {
+		return err
+	}
+	return printItems(cmd, []spool.Item{*item}, queueColumns)
+}
+
+// sendTime resolves --at / --in to a delivery time; zero means send now.
+func sendTime(at string, in time.Duration) (time.Time, error) {
+	if at != "" && in != 0 {
+		return time.Time{}, fmt.Errorf("use either --at or --in, not both")
+	}
+	var due time.Time
+	switch {
+	case at != "":
+		t, err := parseTimeFlag("at", at)
+		if err != nil {
+			return time.Time{}, err
+		}
+		due = t
+	case in != 0:
+		due = time.Now().Add(in)
+	default:
+		return time.Time{}, nil
+	}
+	if !due.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("scheduled time %s is in the past", due.Format("2006-01-02 15:04"))
+	}
+	return due, nil
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/markup"
+	"github.com/piotrwolkowski/tcli/internal/output"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	searchFrom  string
+	searchChat  string
+	searchSince string
+	searchUntil string
+	searchLimit int
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search messages across all your chats",
+	Long: `Search messages across all your chats using Microsoft Search. The query uses
+KQL, so phrases can be quoted and terms combined with AND / OR.
+
+Examples:
+  tcli search kubectl
+  tcli search '"kubectl rollout"' --from alice@example.com --since 2026-09-01
+  tcli search deploy --chat 19:abc123@thread.v2 -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSearch,
+}
+
+// searchResult is a search hit with its chat name resolved for display.
+type searchResult struct {
+	graph.SearchHit
+	ChatName string `json:"chatName"`
+	Snippet  string `json:"snippet"`
+}
+
+var searchColumns = []output.Column[searchResult]{
+	{Name: "chat", Header: "CHAT", Value: func(r searchResult) string { return r.ChatName }},
+	{Name: "sender", Header: "FROM", Value: func(r searchResult) string { return r.Sender }},
+	{Name: "sent", Header: "SENT", Value: func(r searchResult) string { return relativeTime(r.CreatedAt) }},
+	{Name: "snippet", Header: "SNIPPET", Value: func(r searchResult) string { return r.Snippet }},
+	{Name: "link", Header: "LINK", Value: func(r searchResult) string { return r.WebURL }},
+}
+
+func init() {
+	searchCmd.Flags().StringVar(&searchFrom, "from", "", "only messages from this sender (name or email)")
+	searchCmd.Flags().StringVar(&searchChat, "chat", "", "only messages in this chat ID")
+	searchCmd.Flags().StringVar(&searchSince, "since", "", "only messages sent on or after this date/time")
+	searchCmd.Flags().StringVar(&searchUntil, "until", "", "only messages sent on or before this date/time")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 25, "maximum number of results (0 for all)")
+	rootCmd.AddCommand(searchCmd)
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	q := graph.SearchQuery{Query: args[0], From: searchFrom, ChatID: searchChat}
+	if searchSince != "" {
+		t, err := parseTimeFlag("since", searchSince)
+		if err != nil {
+			return usageError{err}
+		}
+		q.Since = t
+	}
+	if searchUntil != "" {
+		t, err := parseTimeFlag("until", searchUntil)
+		if err != nil {
+			return usageError{err}
+		}
+		q.Until = t
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	hits, err := client.SearchMessages(cmd.Context(), q, searchLimit)
+	if err != nil {
+		return err
+	}
+
+	names := chatNames(cmd, client)
+
+	// Highlight matches in bold only when a person is reading a table.
+	start, end := "", ""
+	if (outputFormat == "" || outputFormat == output.FormatTable) && term.IsTerminal(int(os.Stdout.Fd())) {
+		start, end = "\x1b[1m", "\x1b[0m"
+	}
+
+	results := make([]searchResult, len(hits))
+	for i, h := range hits {
+		name := names[h.ChatID]
+		if name == "" {
+			name = h.ChatID
+		}
+		results[i] = searchResult{SearchHit: h, ChatName: name, Snippet: markup.Highlight(h.Summary, start, end)}
+	}
+	return printItems(cmd, results, searchColumns)
+}
+
+// chatNames maps chat IDs to display names. Search results only carry chat
+// IDs, so a failure here degrades to showing IDs rather than failing.
+func chatNames(cmd *cobra.Command, client *graph.Client) map[string]string {
+	names := map[string]string{}
+	chats, err := client.ListChats(cmd.Context())
+	if err != nil {
+		return names
+	}
+	for _, c := range chats {
+		names[c.ID] = graph.ChatDisplayName(c)
+	}
+	return names
+}
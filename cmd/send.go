@@ -4,31 +4,98 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/piotrwolkowski/tcli/config"
+	"github.com/piotrwolkowski/tcli/internal/dedupe"
 	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/output"
+	"github.com/piotrwolkowski/tcli/internal/spool"
 	"github.com/spf13/cobra"
 )
 
+var (
+	sendImportance string
+	sendAt         string
+	sendIn         time.Duration
+	sendOutbox     bool
+	sendKey        string
+	sendDedupe     time.Duration
+	sendEditOnDiff bool
+)
+
 var sendCmd = &cobra.Command{
-	Use:   "send <chat-id> <message>",
+	Use:   "send [chat-id] [message]",
 	Short: "Send a message to a Teams chat",
 	Long: `Send a message to a Teams chat. The message can be provided as an argument or piped via stdin.
+When no chat ID is given in an interactive terminal, a fuzzy finder lists your chats to choose from.
 
 Examples:
   tcli send 19:abc123@thread.v2 "Hello from the CLI"
   echo "Build passed" | tcli send 19:abc123@thread.v2 -
-  some-command | tcli send 19:abc123@thread.v2 -`,
-	Args: cobra.RangeArgs(1, 2),
+  some-command | tcli send 19:abc123@thread.v2 -
+  tcli send 19:abc123@thread.v2 "Prod is down" --importance urgent
+  tcli send 19:abc123@thread.v2 "Standup!" --at "2026-10-19 09:00"
+  tcli send 19:abc123@thread.v2 "Build 42 passed" --idempotency-key build-42
+  tcli send`,
+	Args: cobra.RangeArgs(0, 2),
 	RunE: runSend,
 }
 
+var sentColumns = []output.Column[*graph.SendMessageResponse]{
+	{Name: "id", Header: "MESSAGE ID", Value: func(r *graph.SendMessageResponse) string { return r.ID }},
+	{Name: "created", Header: "CREATED", Value: func(r *graph.SendMessageResponse) string { return r.CreatedAt }},
+}
+
 func init() {
+	sendCmd.Flags().StringVar(&sendImportance, "importance", graph.ImportanceNormal, "message importance: normal, high or urgent (urgent notifies recipients repeatedly)")
+	sendCmd.Flags().StringVar(&sendAt, "at", "", `schedule the message for a date/time, e.g. "2026-10-18 09:00" (see tcli schedule)`)
+	sendCmd.Flags().DurationVar(&sendIn, "in", 0, "schedule the message after a delay, e.g. 2h or 90m (see tcli schedule)")
+	sendCmd.Flags().BoolVar(&sendOutbox, "outbox", false, "if sending fails with a network or server error, save the message to the outbox for retry (or set TCLI_OUTBOX=1)")
+	sendCmd.Flags().StringVar(&sendKey, "idempotency-key", "", "send at most once per key and chat; repeats print the message already sent")
+	sendCmd.Flags().DurationVar(&sendDedupe, "dedupe-window", 0, "skip the message if identical content was sent to the chat within this window, e.g. 10m")
+	sendCmd.Flags().BoolVar(&sendEditOnDiff, "edit-on-change", false, "with --idempotency-key, edit the earlier message when the content differs")
 	rootCmd.AddCommand(sendCmd)
 }
 
 func runSend(cmd *cobra.Command, args []string) error {
-	chatID := args[0]
+	if err := graph.ValidateImportance(sendImportance); err != nil {
+		return usageError{err}
+	}
+	due, err := sendTime(sendAt, sendIn)
+	if err != nil {
+		return usageError{err}
+	}
+	if (sendKey != "" || sendDedupe != 0) && !due.IsZero() {
+		return usageError{fmt.Errorf("--idempotency-key and --dedupe-window cannot be combined with --at or --in")}
+	}
+	if sendEditOnDiff && sendKey == "" {
+		return usageError{fmt.Errorf("--edit-on-change requires --idempotency-key")}
+	}
+	if !due.IsZero() {
+		if err := requireUser("scheduling messages"); err != nil {
+			return err
+		}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var chatID string
+	if len(args) > 0 {
+		chatID = args[0]
+	} else {
+		id, err := pickChat(cmd, client)
+		if err != nil {
+			return err
+		}
+		chatID = id
+		fmt.Fprintln(os.Stderr, "Type your message, then press Ctrl-D to send:")
+	}
 
 	var message string
 	if len(args) == 2 && args[1] != "-" {
@@ -45,12 +112,85 @@ func runSend(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("message cannot be empty")
 	}
 
-	client := graph.NewClient()
-	resp, err := client.SendMessage(cmd.Context(), chatID, message)
+	req := graph.SendMessageRequest{
+		Body:       graph.MessageBody{Content: message},
+		Importance: sendImportance,
+	}
+	if !due.IsZero() {
+		return scheduleSend(cmd, chatID, req, due)
+	}
+
+	key, window := sendKey, time.Duration(0)
+	if key == "" && sendDedupe > 0 {
+		key, window = dedupe.AutoKey(message), sendDedupe
+	}
+	var sent *dedupe.Store
+	if key != "" {
+		if sent, err = sentStore(); err != nil {
+			return err
+		}
+		// Another tcli send with the same key waits until this message is
+		// posted and recorded, and then finds the record.
+		release, err := sent.Lock(cmd.Context(), chatID, key)
+		if err != nil {
+			return err
+		}
+		defer release()
+		prev, err := sent.Lookup(chatID, key, window)
+		if err != nil {
+			return err
+		}
+		if prev != nil {
+			return resendDuplicate(cmd, client, sent, prev, message)
+		}
+	}
+
+	resp, err := client.PostMessage(cmd.Context(), chatID, req)
 	if err != nil {
+		if outboxEnabled(sendOutbox) && graph.IsTransient(err) {
+			return queueFailedSend(cmd, spool.Item{ChatID: chatID, Message: req, DedupeKey: key, DedupeWindow: window}, err)
+		}
 		return err
 	}
 
-	fmt.Printf("Message sent (id: %s, at: %s)\n", resp.ID, resp.CreatedAt)
-	return nil
+	if sent != nil {
+		rec := dedupe.Record{Key: key, ChatID: chatID, MessageID: resp.ID, CreatedAt: resp.CreatedAt, ContentHash: dedupe.Hash(message)}
+		if err := sent.Save(rec); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: message sent but not recorded for deduplication: %v\n", err)
+		}
+	}
+
+	return printItems(cmd, []*graph.SendMessageResponse{resp}, sentColumns)
+}
+
+// sentStore returns the record of messages sent with an idempotency key.
+func sentStore() (*dedupe.Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return dedupe.NewStore(filepath.Join(dir, "sent")), nil
+}
+
+// resendDuplicate handles a send whose key was already used: it prints the
+// earlier message instead of posting again, editing it first with
+// --edit-on-change if the content differs.
+func resendDuplicate(cmd *cobra.Command, client *graph.Client, sent *dedupe.Store, prev *dedupe.Record, message string) error {
+	switch {
+	case !prev.Changed(message):
+		fmt.Fprintf(os.Stderr, "Already sent as message %s; not sending again.\n", prev.MessageID)
+	case sendEditOnDiff:
+		if err := client.UpdateMessage(cmd.Context(), prev.ChatID, prev.MessageID, message); err != nil {
+			return err
+		}
+		prev.ContentHash = dedupe.Hash(message)
+		if err := sent.Save(*prev); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: message edited but not recorded for deduplication: %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Edited message %s with the new content.\n", prev.MessageID)
+	default:
+		fmt.Fprintf(os.Stderr, "Already sent as message %s with different content; not sending again (use --edit-on-change to update it).\n", prev.MessageID)
+	}
+	resp := &graph.SendMessageResponse{ID: prev.MessageID, CreatedAt: prev.CreatedAt}
+	return printItems(cmd, []*graph.SendMessageResponse{resp}, sentColumns)
 }
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeLayouts are the accepted forms for date/time flags. Values without a
+// zone are interpreted in local time.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimeFlag parses a date/time flag value such as "2026-10-18",
+// "2026-10-18 09:00" or an RFC 3339 timestamp.
+func parseTimeFlag(name, value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --%s %q — use YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or RFC 3339", name, value)
+}
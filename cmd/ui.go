@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	uiPollInterval time.Duration
+	uiHistory      int
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Open the full-screen terminal interface",
+	Long: `Open a full-screen interface with your chats on the left, the open chat's
+history on the right and a compose line at the bottom. New messages are
+polled in the background.
+
+Keys:
+  Tab          switch between the chat list and the compose line
+  Up/Down, j/k move through chats
+  Enter        open the selected chat / send the composed message
+  PgUp/PgDn    scroll the message history
+  r            refresh the chat list
+  q, Esc       quit (from the chat list); Ctrl-C quits anywhere`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireUser("opening the terminal interface"); err != nil {
+			return err
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		return tui.Run(cmd.Context(), client, os.Stdin, os.Stdout, tui.Options{
+			PollInterval: uiPollInterval,
+			History:      uiHistory,
+		})
+	},
+}
+
+func init() {
+	uiCmd.Flags().DurationVar(&uiPollInterval, "interval", 5*time.Second, "how often to check the open chat for new messages")
+	uiCmd.Flags().IntVar(&uiHistory, "history", 50, "number of recent messages to load per chat")
+	rootCmd.AddCommand(uiCmd)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cloud names accepted by the cloud setting. GCC (moderate) tenants use the
+// global cloud.
+const (
+	CloudGlobal  = "global"
+	CloudGCCHigh = "gcc-high"
+	CloudDoD     = "dod"
+	CloudChina   = "china"
+)
+
+// Clouds lists the accepted cloud names.
+var Clouds = []string{CloudGlobal, CloudGCCHigh, CloudDoD, CloudChina}
+
+// Endpoints are the hosts of one Microsoft cloud.
+type Endpoints struct {
+	// Authority is the Microsoft identity platform sign-in host.
+	Authority string
+	// Graph is the Graph host. It is also the resource that scopes are
+	// requested for, e.g. "https://graph.microsoft.us/Chat.Read".
+	Graph string
+}
+
+var clouds = map[string]Endpoints{
+	CloudGlobal:  {Authority: "https://login.microsoftonline.com", Graph: "https://graph.microsoft.com"},
+	CloudGCCHigh: {Authority: "https://login.microsoftonline.us", Graph: "https://graph.microsoft.us"},
+	CloudDoD:     {Authority: "https://login.microsoftonline.us", Graph: "https://dod-graph.microsoft.us"},
+	CloudChina:   {Authority: "https://login.chinacloudapi.cn", Graph: "https://microsoftgraph.chinacloudapi.cn"},
+}
+
+// ValidateCloud reports whether name is a known cloud. The empty string
+// means the global cloud.
+func ValidateCloud(name string) error {
+	if _, ok := clouds[name]; ok || name == "" {
+		return nil
+	}
+	return fmt.Errorf("unknown cloud %q — use %s", name, strings.Join(Clouds, ", "))
+}
+
+// GraphHosts returns the Graph host names of every cloud, e.g.
+// "graph.microsoft.com".
+func GraphHosts() []string {
+	hosts := make([]string, 0, len(clouds))
+	for _, name := range Clouds {
+		hosts = append(hosts, strings.TrimPrefix(clouds[name].Graph, "https://"))
+	}
+	return hosts
+}
+
+// CloudEndpoints returns the hosts of the configured cloud.
+func (c *Config) CloudEndpoints() Endpoints {
+	if e, ok := clouds[c.Cloud]; ok {
+		return e
+	}
+	return clouds[CloudGlobal]
+}
+
+// Authority returns the sign-in host: AuthorityURL when set, otherwise the
+// cloud's.
+func (c *Config) Authority() string {
+	if c.AuthorityURL != "" {
+		return strings.TrimRight(c.AuthorityURL, "/")
+	}
+	return c.CloudEndpoints().Authority
+}
+
+// GraphBaseURL returns the versioned Graph endpoint: GraphURL when set,
+// otherwise the cloud's.
+func (c *Config) GraphBaseURL() string {
+	if c.GraphURL != "" {
+		return strings.TrimRight(c.GraphURL, "/")
+	}
+	return c.CloudEndpoints().Graph + "/v1.0"
+}
+
+// Scope returns the fully qualified Graph permission, e.g. "Chat.Read" in
+// the configured cloud. URL overrides do not change the resource a token is
+// issued for.
+func (c *Config) Scope(permission string) string {
+	return c.CloudEndpoints().Graph + "/" + permission
+}
@@ -0,0 +1,79 @@
+package config
+
+import "testing"
+
+func TestCloudEndpoints(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           Config
+		wantAuthority string
+		wantGraph     string
+		wantScope     string
+	}{
+		{
+			name:          "default is global",
+			cfg:           Config{},
+			wantAuthority: "https://login.microsoftonline.com",
+			wantGraph:     "https://graph.microsoft.com/v1.0",
+			wantScope:     "https://graph.microsoft.com/Chat.Read",
+		},
+		{
+			name:          "gcc high",
+			cfg:           Config{Cloud: CloudGCCHigh},
+			wantAuthority: "https://login.microsoftonline.us",
+			wantGraph:     "https://graph.microsoft.us/v1.0",
+			wantScope:     "https://graph.microsoft.us/Chat.Read",
+		},
+		{
+			name:          "dod",
+			cfg:           Config{Cloud: CloudDoD},
+			wantAuthority: "https://login.microsoftonline.us",
+			wantGraph:     "https://dod-graph.microsoft.us/v1.0",
+			wantScope:     "https://dod-graph.microsoft.us/Chat.Read",
+		},
+		{
+			name:          "china",
+			cfg:           Config{Cloud: CloudChina},
+			wantAuthority: "https://login.chinacloudapi.cn",
+			wantGraph:     "https://microsoftgraph.chinacloudapi.cn/v1.0",
+			wantScope:     "https://microsoftgraph.chinacloudapi.cn/Chat.Read",
+		},
+		{
+			name:          "overrides keep the cloud's scopes",
+			cfg:           Config{Cloud: CloudGCCHigh, AuthorityURL: "http://localhost:8080/", GraphURL: "http://localhost:8080/v1.0/"},
+			wantAuthority: "http://localhost:8080",
+			wantGraph:     "http://localhost:8080/v1.0",
+			wantScope:     "https://graph.microsoft.us/Chat.Read",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.Authority(); got != tt.wantAuthority {
+				t.Errorf("Authority() = %q, want %q", got, tt.wantAuthority)
+			}
+			if got := tt.cfg.GraphBaseURL(); got != tt.wantGraph {
+				t.Errorf("GraphBaseURL() = %q, want %q", got, tt.wantGraph)
+			}
+			if got := tt.cfg.Scope("Chat.Read"); got != tt.wantScope {
+				t.Errorf("Scope() = %q, want %q", got, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownCloud(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TCLI_CLIENT_ID", "client")
+	t.Setenv("TCLI_TENANT_ID", "tenant")
+	t.Setenv("TCLI_CLOUD", "mars")
+	if _, err := Load(); err == nil {
+		t.Error("Load() with TCLI_CLOUD=mars succeeded")
+	}
+
+	t.Setenv("TCLI_CLOUD", CloudChina)
+	cfg, err := Load()
+	if err != nil || cfg.Cloud != CloudChina {
+		t.Errorf("Load() = %+v, %v; want the china cloud", cfg, err)
+	}
+}
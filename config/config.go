@@ -1,16 +1,48 @@
 package config
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 type Config struct {
 	ClientID string `json:"clientId"`
 	TenantID string `json:"tenantId"`
+	// Cloud selects the Microsoft cloud to sign in to and call; see Clouds.
+	// Empty means the global cloud.
+	Cloud string `json:"cloud,omitempty"`
+	// ClientSecret, ClientSecretFile, ClientCertificate or a federated token
+	// switch tcli to app-only authentication with the client credentials
+	// grant. The secret
+	// itself is only read from TCLI_CLIENT_SECRET and never saved.
+	ClientSecret     string `json:"-"`
+	ClientSecretFile string `json:"clientSecretFile,omitempty"`
+	// ClientCertificate is a PEM file holding the certificate registered for
+	// the app and, unless ClientKey is set, its RSA private key.
+	ClientCertificate string `json:"clientCertificate,omitempty"`
+	ClientKey         string `json:"clientKey,omitempty"`
+	// FederatedTokenFile holds an OIDC token from another identity provider,
+	// such as a Kubernetes service account, that Entra ID trusts through a
+	// federated credential on the app. It is read again for every sign-in,
+	// as such tokens are short-lived and rotated. FederatedToken is the token
+	// itself and is only read from TCLI_FEDERATED_TOKEN.
+	FederatedTokenFile string `json:"federatedTokenFile,omitempty"`
+	FederatedToken     string `json:"-"`
+	// AuthorityURL and GraphURL replace the sign-in and Graph endpoints of
+	// the cloud, e.g. to run against a local fake or a proxy.
+	AuthorityURL string `json:"authorityUrl,omitempty"`
+	GraphURL     string `json:"graphUrl,omitempty"`
+	// RateLimit (requests per second), RateBurst and MaxInFlight limit the
+	// requests one tcli process sends to Graph. Zero keeps the default and
+	// a negative value turns the limit off.
+	RateLimit   float64 `json:"rateLimit,omitempty"`
+	RateBurst   int     `json:"rateBurst,omitempty"`
+	MaxInFlight int     `json:"maxInFlight,omitempty"`
 }
 
 func Dir() (string, error) {
@@ -25,24 +57,51 @@ func Load() (*Config, error) {
 	cfg := &Config{
 		ClientID: os.Getenv("TCLI_CLIENT_ID"),
 		TenantID: os.Getenv("TCLI_TENANT_ID"),
+		Cloud:    os.Getenv("TCLI_CLOUD"),
+
+		ClientSecret:      os.Getenv("TCLI_CLIENT_SECRET"),
+		ClientSecretFile:  os.Getenv("TCLI_CLIENT_SECRET_FILE"),
+		ClientCertificate: os.Getenv("TCLI_CLIENT_CERTIFICATE"),
+		ClientKey:         os.Getenv("TCLI_CLIENT_KEY"),
+
+		FederatedToken:     os.Getenv("TCLI_FEDERATED_TOKEN"),
+		FederatedTokenFile: cmp.Or(os.Getenv("TCLI_FEDERATED_TOKEN_FILE"), os.Getenv("AZURE_FEDERATED_TOKEN_FILE")),
+
+		AuthorityURL: os.Getenv("TCLI_AUTHORITY_URL"),
+		GraphURL:     os.Getenv("TCLI_GRAPH_URL"),
+	}
+	if v := os.Getenv("TCLI_RATE_LIMIT"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TCLI_RATE_LIMIT %q — use requests per second, e.g. 5", v)
+		}
+		cfg.RateLimit = rate
+	}
+	if v := os.Getenv("TCLI_RATE_BURST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TCLI_RATE_BURST %q — use a number of requests, e.g. 10", v)
+		}
+		cfg.RateBurst = n
+	}
+	if v := os.Getenv("TCLI_MAX_IN_FLIGHT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TCLI_MAX_IN_FLIGHT %q — use a number of requests, e.g. 4", v)
+		}
+		cfg.MaxInFlight = n
 	}
 
-	dir, err := Dir()
-	if err != nil {
+	if _, err := Dir(); err != nil {
 		return cfg, nil
 	}
-
-	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return cfg, validate(cfg)
-		}
-		return nil, fmt.Errorf("reading config: %w", err)
+	if err := ValidateCloud(cfg.Cloud); err != nil {
+		return nil, fmt.Errorf("invalid TCLI_CLOUD: %w", err)
 	}
 
-	var fileCfg Config
-	if err := json.Unmarshal(data, &fileCfg); err != nil {
-		return nil, fmt.Errorf("parsing config.json: %w", err)
+	fileCfg, err := LoadFile()
+	if err != nil {
+		return nil, err
 	}
 
 	// Env vars take precedence over file values
@@ -52,10 +111,65 @@ func Load() (*Config, error) {
 	if cfg.TenantID == "" {
 		cfg.TenantID = fileCfg.TenantID
 	}
+	if cfg.Cloud == "" {
+		if err := ValidateCloud(fileCfg.Cloud); err != nil {
+			return nil, fmt.Errorf("config.json: %w", err)
+		}
+		cfg.Cloud = fileCfg.Cloud
+	}
+	if cfg.ClientSecretFile == "" {
+		cfg.ClientSecretFile = fileCfg.ClientSecretFile
+	}
+	if cfg.ClientCertificate == "" {
+		cfg.ClientCertificate = fileCfg.ClientCertificate
+	}
+	if cfg.ClientKey == "" {
+		cfg.ClientKey = fileCfg.ClientKey
+	}
+	if cfg.FederatedTokenFile == "" {
+		cfg.FederatedTokenFile = fileCfg.FederatedTokenFile
+	}
+	if cfg.AuthorityURL == "" {
+		cfg.AuthorityURL = fileCfg.AuthorityURL
+	}
+	if cfg.GraphURL == "" {
+		cfg.GraphURL = fileCfg.GraphURL
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = fileCfg.RateLimit
+	}
+	if cfg.RateBurst == 0 {
+		cfg.RateBurst = fileCfg.RateBurst
+	}
+	if cfg.MaxInFlight == 0 {
+		cfg.MaxInFlight = fileCfg.MaxInFlight
+	}
 
 	return cfg, validate(cfg)
 }
 
+// LoadFile reads config.json alone, without environment overrides, for
+// commands that update it. A missing file gives an empty Config.
+func LoadFile() (*Config, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config.json: %w", err)
+	}
+	return &cfg, nil
+}
+
 func Save(cfg *Config) error {
 	dir, err := Dir()
 	if err != nil {
@@ -72,6 +186,13 @@ func Save(cfg *Config) error {
 	return os.WriteFile(filepath.Join(dir, "config.json"), data, 0600)
 }
 
+// AppOnly reports whether client credentials are configured, so tcli signs
+// in as the app rather than as a user.
+func (c *Config) AppOnly() bool {
+	return c.ClientSecret != "" || c.ClientSecretFile != "" || c.ClientCertificate != "" ||
+		c.FederatedToken != "" || c.FederatedTokenFile != ""
+}
+
 func validate(cfg *Config) error {
 	if cfg.ClientID == "" || cfg.TenantID == "" {
 		return fmt.Errorf("clientId and tenantId are required — set TCLI_CLIENT_ID / TCLI_TENANT_ID env vars or run: tcli config")
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestLoadRateLimitOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := Save(&Config{ClientID: "client", TenantID: "tenant", RateLimit: 2, RateBurst: 5, MaxInFlight: 2}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TCLI_RATE_LIMIT", "")
+	t.Setenv("TCLI_RATE_BURST", "20")
+	t.Setenv("TCLI_MAX_IN_FLIGHT", "-1")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimit != 2 || cfg.RateBurst != 20 || cfg.MaxInFlight != -1 {
+		t.Errorf("Load() = rate %v, burst %d, in flight %d; want 2, 20, -1", cfg.RateLimit, cfg.RateBurst, cfg.MaxInFlight)
+	}
+
+	t.Setenv("TCLI_RATE_BURST", "lots")
+	if _, err := Load(); err == nil {
+		t.Error("Load() with TCLI_RATE_BURST=lots succeeded")
+	}
+}
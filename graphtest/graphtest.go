@@ -0,0 +1,803 @@
+// Package graphtest runs an in-process fake of the Microsoft identity
+// platform and the Microsoft Graph chat endpoints tcli uses, so clients can
+// be tested end to end without a tenant or credentials.
+//
+// Point tcli at it with TCLI_AUTHORITY_URL=srv.AuthorityURL() and
+// TCLI_GRAPH_URL=srv.GraphURL() (or graph.WithBaseURL), seed it with AddChat
+// and AddMessages, and inject failures with Fail and Throttle.
+package graphtest
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// User is the signed-in user of the fake tenant.
+const (
+	UserID   = "00000000-0000-0000-0000-000000000001"
+	UserName = "Test User"
+)
+
+// ClientSecret is the client secret the fake accepts for the client
+// credentials grant. Any well-formed JWT is accepted as a client assertion.
+const ClientSecret = "graphtest-secret"
+
+// Chat is a chat seeded into the fake.
+type Chat struct {
+	ID          string
+	Topic       string
+	ChatType    string // defaults to "group"
+	Members     []Member
+	LastUpdated time.Time
+}
+
+// Member is a chat member.
+type Member struct {
+	DisplayName string
+	Email       string
+}
+
+// Message is a chat message held by the fake.
+type Message struct {
+	ID          string
+	From        string // sender display name; empty for system messages
+	Content     string
+	ContentType string // defaults to "html"
+	Importance  string
+	CreatedAt   time.Time
+	EditedAt    time.Time
+	// Reactions holds the reaction types added by the signed-in user.
+	Reactions []string
+	// Attachments are the files attached to the message.
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a message, by reference to OneDrive.
+type Attachment struct {
+	ID         string
+	Name       string
+	ContentURL string
+}
+
+// Fault makes matching Graph requests fail.
+type Fault struct {
+	// Method and Path select requests; empty matches any. Path is a prefix
+	// of the Graph path without the version, e.g. "/me/chats".
+	Method string
+	Path   string
+	// Status is the HTTP status to return.
+	Status int
+	// Code and Message fill the Graph error body.
+	Code    string
+	Message string
+	// RetryAfter, when set, is sent as the Retry-After header.
+	RetryAfter string
+	// Times is how many requests fail; zero means one.
+	Times int
+}
+
+// Request is a request the fake received.
+type Request struct {
+	Method string
+	// Path is the request path including the version prefix, e.g.
+	// "/v1.0/me/chats", or the tenant for sign-in requests.
+	Path   string
+	Query  url.Values
+	Status int
+}
+
+// Server is a running fake. Its methods are safe for concurrent use.
+type Server struct {
+	// URL is the root of the fake, e.g. "http://127.0.0.1:41234".
+	URL string
+
+	srv *httptest.Server
+
+	mu           sync.Mutex
+	chats        []*Chat
+	messages     map[string][]*Message
+	files        map[string][]byte // OneDrive files by path
+	faults       []*Fault
+	requests     []Request
+	accessTokens map[string]bool
+	appTokens    map[string]bool // app-only access tokens, a subset of accessTokens
+	refresh      map[string]bool
+	deviceCodes  map[string]int // remaining pending polls
+	pendingPolls int
+	consented    []string // granted permissions; nil grants all
+	pageSize     int
+	nextID       int
+}
+
+// NewServer starts a fake that is shut down when the test ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		messages:     map[string][]*Message{},
+		files:        map[string][]byte{},
+		accessTokens: map[string]bool{},
+		appTokens:    map[string]bool{},
+		refresh:      map[string]bool{},
+		deviceCodes:  map[string]int{},
+		pageSize:     50,
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// GraphURL is the base URL for Graph requests, in the form of
+// graph.DefaultBaseURL.
+func (s *Server) GraphURL() string { return s.URL + "/v1.0" }
+
+// AuthorityURL is the sign-in host; tenants live below it.
+func (s *Server) AuthorityURL() string { return s.URL }
+
+// AddChat seeds a chat.
+func (s *Server) AddChat(c Chat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.ChatType == "" {
+		c.ChatType = "group"
+	}
+	s.chats = append(s.chats, &c)
+}
+
+// AddMessages appends messages to a chat, assigning IDs and creation times
+// where they are missing.
+func (s *Server) AddMessages(chatID string, msgs ...Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range msgs {
+		s.addMessage(chatID, m)
+	}
+}
+
+// Messages returns a chat's messages, oldest first.
+func (s *Server) Messages(chatID string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Message, len(s.messages[chatID]))
+	for i, m := range s.messages[chatID] {
+		out[i] = *m
+		out[i].Reactions = slices.Clone(m.Reactions)
+		out[i].Attachments = slices.Clone(m.Attachments)
+	}
+	return out
+}
+
+// File returns the content of a file uploaded to the signed-in user's
+// OneDrive, by path below the drive root, e.g.
+// "Microsoft Teams Chat Files/report.pdf".
+func (s *Server) File(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[path]
+	return data, ok
+}
+
+// SetPageSize caps how many items each page of a listing holds.
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// SetPendingPolls makes device code logins report authorization_pending for
+// the first n token polls, as if the user had not finished signing in yet.
+func (s *Server) SetPendingPolls(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pendingPolls = n
+}
+
+// RestrictPermissions makes sign-in and token refresh fail with
+// consent_required when they ask for a Graph permission other than perms, as
+// in a tenant that only consented to those. By default every permission is
+// granted.
+func (s *Server) RestrictPermissions(perms ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.consented = slices.Clone(perms)
+}
+
+// Fail injects a failure for matching Graph requests.
+func (s *Server) Fail(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f.Times <= 0 {
+		f.Times = 1
+	}
+	s.faults = append(s.faults, &f)
+}
+
+// Throttle answers the next n Graph requests with 429 and Retry-After: 0.
+func (s *Server) Throttle(n int) {
+	s.Fail(Fault{Status: http.StatusTooManyRequests, Code: "TooManyRequests", Message: "Too many requests", RetryAfter: "0", Times: n})
+}
+
+// IssueToken returns a valid access token without going through sign-in,
+// for use with graph.WithTokenSource.
+func (s *Server) IssueToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tok := s.newID("access")
+	s.accessTokens[tok] = true
+	return tok
+}
+
+// IssueAppToken returns a valid app-only access token, as if obtained with
+// the client credentials grant. Requests under /me fail with it.
+func (s *Server) IssueAppToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tok := s.newID("app-access")
+	s.accessTokens[tok] = true
+	s.appTokens[tok] = true
+	return tok
+}
+
+// RevokeTokens invalidates every access and refresh token issued so far.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.accessTokens)
+	clear(s.appTokens)
+	clear(s.refresh)
+}
+
+// ExpireAccessTokens invalidates issued access tokens but keeps refresh
+// tokens, as if the access tokens had timed out.
+func (s *Server) ExpireAccessTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.accessTokens)
+	clear(s.appTokens)
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+func (s *Server) addMessage(chatID string, m Message) *Message {
+	if m.ID == "" {
+		m.ID = strconv.FormatInt(time.Now().UnixMilli()*1000+int64(s.nextID), 10)
+		s.nextID++
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+		if prev := s.messages[chatID]; len(prev) > 0 && !m.CreatedAt.After(prev[len(prev)-1].CreatedAt) {
+			m.CreatedAt = prev[len(prev)-1].CreatedAt.Add(time.Millisecond)
+		}
+	}
+	if m.ContentType == "" {
+		m.ContentType = "html"
+	}
+	s.messages[chatID] = append(s.messages[chatID], &m)
+	return &m
+}
+
+// statusRecorder captures the status written by a handler for the request log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	rec.Header().Set("request-id", fmt.Sprintf("graphtest-%d", time.Now().UnixNano()))
+	if id := r.Header.Get("client-request-id"); id != "" {
+		rec.Header().Set("client-request-id", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() {
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Status: rec.status})
+	}()
+
+	if path, ok := strings.CutPrefix(r.URL.Path, "/v1.0"); ok {
+		s.serveGraph(rec, r, path)
+		return
+	}
+	s.serveIdentity(rec, r)
+}
+
+// serveIdentity emulates the device code and token endpoints of
+// https://login.microsoftonline.com/{tenant}/oauth2/v2.0/.
+func (s *Server) serveIdentity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, "invalid_request", err.Error())
+		return
+	}
+	if p := s.unconsented(r.Form.Get("scope")); p != "" {
+		oauthError(w, "consent_required", "The user or administrator has not consented to use the application for "+p+".")
+		return
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/devicecode"):
+		code := s.newID("device-code")
+		s.deviceCodes[code] = s.pendingPolls
+		writeJSON(w, http.StatusOK, map[string]any{
+			"device_code":      code,
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": s.URL + "/devicelogin",
+			"expires_in":       900,
+			"interval":         1,
+			"message":          "To sign in, use a web browser to open " + s.URL + "/devicelogin and enter the code ABCD-EFGH.",
+		})
+	case strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token"):
+		s.serveToken(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	switch r.Form.Get("grant_type") {
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		code := r.Form.Get("device_code")
+		pending, ok := s.deviceCodes[code]
+		if !ok {
+			oauthError(w, "expired_token", "The device code has expired or is unknown.")
+			return
+		}
+		if pending > 0 {
+			s.deviceCodes[code] = pending - 1
+			oauthError(w, "authorization_pending", "The user has not yet completed sign-in.")
+			return
+		}
+		delete(s.deviceCodes, code)
+	case "refresh_token":
+		tok := r.Form.Get("refresh_token")
+		if !s.refresh[tok] {
+			oauthError(w, "invalid_grant", "The refresh token is invalid or has expired.")
+			return
+		}
+		// Rotate the refresh token, as Entra ID may.
+		delete(s.refresh, tok)
+	case "client_credentials":
+		assertion := r.Form.Get("client_assertion")
+		if r.Form.Get("client_secret") != ClientSecret && strings.Count(assertion, ".") != 2 {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client", "error_description": "Invalid client secret or assertion provided."})
+			return
+		}
+		access := s.newID("app-access")
+		s.accessTokens[access] = true
+		s.appTokens[access] = true
+		writeJSON(w, http.StatusOK, map[string]any{"token_type": "Bearer", "access_token": access, "expires_in": 3600})
+		return
+	default:
+		oauthError(w, "unsupported_grant_type", "Unsupported grant type "+r.Form.Get("grant_type"))
+		return
+	}
+
+	access, refresh := s.newID("access"), s.newID("refresh")
+	s.accessTokens[access] = true
+	s.refresh[refresh] = true
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token_type":    "Bearer",
+		"access_token":  access,
+		"refresh_token": refresh,
+		"expires_in":    3600,
+	})
+}
+
+// unconsented returns the first permission in scope that has not been
+// granted, or "" if there is none.
+func (s *Server) unconsented(scope string) string {
+	if s.consented == nil {
+		return ""
+	}
+	for _, sc := range strings.Fields(scope) {
+		if i := strings.LastIndex(sc, "/"); i >= 0 && sc[i+1:] != ".default" && !slices.Contains(s.consented, sc[i+1:]) {
+			return sc[i+1:]
+		}
+	}
+	return ""
+}
+
+func oauthError(w http.ResponseWriter, code, desc string) {
+	writeJSON(w, http.StatusBadRequest, map[string]any{"error": code, "error_description": desc})
+}
+
+func (s *Server) serveGraph(w http.ResponseWriter, r *http.Request, path string) {
+	for _, f := range s.faults {
+		if f.Times > 0 && (f.Method == "" || f.Method == r.Method) && strings.HasPrefix(path, f.Path) {
+			f.Times--
+			if f.RetryAfter != "" {
+				w.Header().Set("Retry-After", f.RetryAfter)
+			}
+			graphError(w, f.Status, f.Code, f.Message)
+			return
+		}
+	}
+
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !s.accessTokens[token] {
+		graphError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "Access token is empty, invalid or has expired.")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if s.appTokens[token] && parts[0] == "me" {
+		graphError(w, http.StatusBadRequest, "BadRequest", "/me request is only valid with delegated authentication flow.")
+		return
+	}
+	switch {
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/me/drive/root:/") && strings.HasSuffix(path, ":/content"):
+		s.upload(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/me/drive/root:/"), ":/content"))
+	case r.Method == http.MethodPost && match(parts, "$batch"):
+		s.batch(w, r)
+	case r.Method == http.MethodGet && (match(parts, "me", "chats") || match(parts, "users", "*", "chats")):
+		s.listChats(w, r, path)
+	case r.Method == http.MethodGet && match(parts, "me", "chats", "*"):
+		s.getChat(w, parts[2])
+	case r.Method == http.MethodGet && match(parts, "chats", "*"):
+		s.getChat(w, parts[1])
+	case r.Method == http.MethodGet && match(parts, "me", "chats", "*", "messages"):
+		s.listMessages(w, r, parts[2])
+	case r.Method == http.MethodGet && match(parts, "chats", "*", "messages"):
+		s.listMessages(w, r, parts[1])
+	case r.Method == http.MethodPost && match(parts, "me", "chats", "*", "messages"):
+		s.postMessage(w, r, parts[2])
+	case r.Method == http.MethodPatch && match(parts, "chats", "*", "messages", "*"):
+		s.updateMessage(w, r, parts[1], parts[3])
+	case r.Method == http.MethodPost && match(parts, "chats", "*", "messages", "*", "setReaction"):
+		s.react(w, r, parts[1], parts[3], true)
+	case r.Method == http.MethodPost && match(parts, "chats", "*", "messages", "*", "unsetReaction"):
+		s.react(w, r, parts[1], parts[3], false)
+	default:
+		graphError(w, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("graphtest does not implement %s %s", r.Method, path))
+	}
+}
+
+// maxBatch is the most sub-requests Graph accepts in one $batch call.
+const maxBatch = 20
+
+// batch emulates JSON batching: each sub-request is served as if it had been
+// sent on its own, so faults and throttling apply to sub-requests too.
+func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Requests []struct {
+			ID     string          `json:"id"`
+			Method string          `json:"method"`
+			URL    string          `json:"url"`
+			Body   json.RawMessage `json:"body"`
+		} `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		graphError(w, http.StatusBadRequest, "BadRequest", "Invalid batch payload: "+err.Error())
+		return
+	}
+	if len(in.Requests) > maxBatch {
+		graphError(w, http.StatusBadRequest, "BadRequest", fmt.Sprintf("A batch may contain at most %d requests.", maxBatch))
+		return
+	}
+
+	responses := make([]map[string]any, 0, len(in.Requests))
+	for _, item := range in.Requests {
+		sub, err := http.NewRequest(item.Method, s.URL+"/v1.0"+item.URL, bytes.NewReader(item.Body))
+		if err != nil {
+			graphError(w, http.StatusBadRequest, "BadRequest", "Invalid request URL "+item.URL)
+			return
+		}
+		sub.Header.Set("Authorization", r.Header.Get("Authorization"))
+
+		rec := httptest.NewRecorder()
+		path := strings.TrimPrefix(sub.URL.Path, "/v1.0")
+		s.serveGraph(rec, sub, path)
+		s.requests = append(s.requests, Request{Method: sub.Method, Path: sub.URL.Path, Query: sub.URL.Query(), Status: rec.Code})
+
+		headers := map[string]string{}
+		for k := range rec.Header() {
+			headers[k] = rec.Header().Get(k)
+		}
+		resp := map[string]any{"id": item.ID, "status": rec.Code, "headers": headers}
+		if body := bytes.TrimSpace(rec.Body.Bytes()); len(body) > 0 {
+			resp["body"] = json.RawMessage(body)
+		}
+		responses = append(responses, resp)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"responses": responses})
+}
+
+// match reports whether path segments match pattern, where "*" matches any
+// one segment.
+func match(parts []string, pattern ...string) bool {
+	if len(parts) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) findChat(id string) *Chat {
+	for _, c := range s.chats {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Server) findMessage(chatID, id string) *Message {
+	for _, m := range s.messages[chatID] {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *Server) listChats(w http.ResponseWriter, r *http.Request, path string) {
+	values := make([]any, len(s.chats))
+	for i, c := range s.chats {
+		values[i] = chatJSON(c)
+	}
+	s.writePage(w, r, path, values)
+}
+
+func (s *Server) getChat(w http.ResponseWriter, id string) {
+	c := s.findChat(id)
+	if c == nil {
+		graphError(w, http.StatusNotFound, "NotFound", "Chat "+id+" was not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, chatJSON(c))
+}
+
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request, chatID string) {
+	if s.findChat(chatID) == nil {
+		graphError(w, http.StatusNotFound, "NotFound", "Chat "+chatID+" was not found.")
+		return
+	}
+	var before time.Time
+	if f := r.URL.Query().Get("$filter"); f != "" {
+		ts, ok := strings.CutPrefix(f, "createdDateTime lt ")
+		t, err := time.Parse(time.RFC3339, ts)
+		if !ok || err != nil {
+			graphError(w, http.StatusBadRequest, "BadRequest", "Unsupported $filter "+f)
+			return
+		}
+		before = t
+	}
+
+	// Newest first, as Graph returns them.
+	var values []any
+	msgs := s.messages[chatID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if !before.IsZero() && !msgs[i].CreatedAt.Before(before) {
+			continue
+		}
+		values = append(values, messageJSON(msgs[i]))
+	}
+	s.writePage(w, r, strings.TrimPrefix(r.URL.Path, "/v1.0"), values)
+}
+
+// writePage writes one page of values, honouring $top and $skiptoken, with
+// an absolute @odata.nextLink when more remain.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, path string, values []any) {
+	q := r.URL.Query()
+	size := s.pageSize
+	if top, err := strconv.Atoi(q.Get("$top")); err == nil && top > 0 && top < size {
+		size = top
+	}
+	skip, _ := strconv.Atoi(q.Get("$skiptoken"))
+	skip = min(skip, len(values))
+	end := min(skip+size, len(values))
+
+	page := map[string]any{"value": values[skip:end]}
+	if values == nil {
+		page["value"] = []any{}
+	}
+	if end < len(values) {
+		q.Set("$skiptoken", strconv.Itoa(end))
+		page["@odata.nextLink"] = s.GraphURL() + path + "?" + q.Encode()
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+type messageRequest struct {
+	Body struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body"`
+	Importance  string `json:"importance"`
+	Attachments []struct {
+		ID          string `json:"id"`
+		ContentType string `json:"contentType"`
+		ContentURL  string `json:"contentUrl"`
+		Name        string `json:"name"`
+	} `json:"attachments"`
+}
+
+// upload stores a file in OneDrive. Like conflictBehavior=rename, it never
+// replaces an existing file.
+func (s *Server) upload(w http.ResponseWriter, r *http.Request, file string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		graphError(w, http.StatusBadRequest, "BadRequest", "Could not read upload.")
+		return
+	}
+	dir, name := path.Split(file)
+	ext := path.Ext(name)
+	for i := 1; ; i++ {
+		if _, exists := s.files[dir+name]; !exists {
+			break
+		}
+		name = fmt.Sprintf("%s %d%s", strings.TrimSuffix(path.Base(file), ext), i, ext)
+	}
+	s.files[dir+name] = data
+
+	s.nextID++
+	guid := fmt.Sprintf("00000000-0000-0000-0000-%012d", s.nextID)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"id":     s.newID("item"),
+		"name":   name,
+		"size":   len(data),
+		"webUrl": s.URL + "/personal/" + url.PathEscape(UserName) + "/Documents/" + dir + url.PathEscape(name),
+		"eTag":   `"{` + guid + `},1"`,
+	})
+}
+
+func (s *Server) postMessage(w http.ResponseWriter, r *http.Request, chatID string) {
+	if s.findChat(chatID) == nil {
+		graphError(w, http.StatusNotFound, "NotFound", "Chat "+chatID+" was not found.")
+		return
+	}
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Body.Content == "" {
+		graphError(w, http.StatusBadRequest, "BadRequest", "Message body is missing or invalid.")
+		return
+	}
+	msg := Message{
+		From:        UserName,
+		Content:     req.Body.Content,
+		ContentType: req.Body.ContentType,
+		Importance:  req.Importance,
+	}
+	for _, a := range req.Attachments {
+		if a.ContentType != "reference" || !strings.Contains(req.Body.Content, `<attachment id="`+a.ID+`">`) {
+			graphError(w, http.StatusBadRequest, "BadRequest", "Attachment "+a.ID+" is not referenced in the message body.")
+			return
+		}
+		msg.Attachments = append(msg.Attachments, Attachment{ID: a.ID, Name: a.Name, ContentURL: a.ContentURL})
+	}
+	m := s.addMessage(chatID, msg)
+	writeJSON(w, http.StatusCreated, messageJSON(m))
+}
+
+func (s *Server) updateMessage(w http.ResponseWriter, r *http.Request, chatID, id string) {
+	m := s.findMessage(chatID, id)
+	if m == nil {
+		graphError(w, http.StatusNotFound, "NotFound", "Message "+id+" was not found.")
+		return
+	}
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		graphError(w, http.StatusBadRequest, "BadRequest", "Invalid message update.")
+		return
+	}
+	m.Content = req.Body.Content
+	m.EditedAt = time.Now()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) react(w http.ResponseWriter, r *http.Request, chatID, id string, set bool) {
+	m := s.findMessage(chatID, id)
+	if m == nil {
+		graphError(w, http.StatusNotFound, "NotFound", "Message "+id+" was not found.")
+		return
+	}
+	var req struct {
+		ReactionType string `json:"reactionType"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReactionType == "" {
+		graphError(w, http.StatusBadRequest, "BadRequest", "reactionType is required.")
+		return
+	}
+	m.Reactions = slices.DeleteFunc(m.Reactions, func(t string) bool { return t == req.ReactionType })
+	if set {
+		m.Reactions = append(m.Reactions, req.ReactionType)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func chatJSON(c *Chat) map[string]any {
+	members := make([]map[string]any, len(c.Members))
+	for i, m := range c.Members {
+		members[i] = map[string]any{"displayName": m.DisplayName, "email": m.Email}
+	}
+	updated := c.LastUpdated
+	if updated.IsZero() {
+		updated = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return map[string]any{
+		"id":                  c.ID,
+		"topic":               c.Topic,
+		"chatType":            c.ChatType,
+		"lastUpdatedDateTime": updated.UTC().Format(time.RFC3339),
+		"members":             members,
+	}
+}
+
+func messageJSON(m *Message) map[string]any {
+	out := map[string]any{
+		"id":              m.ID,
+		"messageType":     "message",
+		"createdDateTime": m.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"importance":      cmp.Or(m.Importance, "normal"),
+		"body":            map[string]any{"contentType": m.ContentType, "content": m.Content},
+		"from":            nil,
+	}
+	if m.From != "" {
+		id := "user-" + strings.ToLower(strings.ReplaceAll(m.From, " ", "-"))
+		if m.From == UserName {
+			id = UserID
+		}
+		out["from"] = map[string]any{"user": map[string]any{"id": id, "displayName": m.From}}
+	}
+	if !m.EditedAt.IsZero() {
+		out["lastEditedDateTime"] = m.EditedAt.UTC().Format(time.RFC3339Nano)
+	}
+	reactions := make([]map[string]any, len(m.Reactions))
+	for i, t := range m.Reactions {
+		reactions[i] = map[string]any{
+			"reactionType":    t,
+			"createdDateTime": m.CreatedAt.UTC().Format(time.RFC3339Nano),
+			"user":            map[string]any{"user": map[string]any{"id": UserID, "displayName": UserName}},
+		}
+	}
+	out["reactions"] = reactions
+	attachments := make([]map[string]any, len(m.Attachments))
+	for i, a := range m.Attachments {
+		attachments[i] = map[string]any{"id": a.ID, "contentType": "reference", "contentUrl": a.ContentURL, "name": a.Name}
+	}
+	out["attachments"] = attachments
+	return out
+}
+
+func graphError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{"error": map[string]any{"code": code, "message": message}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/config"
+)
+
+// ErrCredentialsRejected is returned when the token endpoint refuses the
+// app's client credentials.
+var ErrCredentialsRejected = errors.New("app credentials rejected")
+
+// AppTokenSource signs in as the app itself with the OAuth2 client
+// credentials grant, for unattended use where nobody can complete a device
+// code sign-in. The app proves its identity with a client secret, a
+// certificate, or a federated token from a trusted identity provider.
+// Tokens are kept in memory and renewed shortly before they expire. It
+// satisfies graph.TokenSource.
+//
+// App-only tokens carry the application permissions granted to the app
+// registration, and Graph calls that act as "me" do not work with them.
+type AppTokenSource struct {
+	cfg  *config.Config
+	cred clientCredential
+
+	mu    sync.Mutex
+	token TokenCache
+}
+
+// clientCredential authenticates the app in a client credentials request.
+type clientCredential interface {
+	// apply adds the credential to the form sent to the token endpoint,
+	// whose URL is audience.
+	apply(values url.Values, audience string) error
+}
+
+// NewAppTokenSource returns a token source for the client secret,
+// certificate or federated token in cfg.
+func NewAppTokenSource(cfg *config.Config) (*AppTokenSource, error) {
+	cred, err := loadCredential(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &AppTokenSource{cfg: cfg, cred: cred}, nil
+}
+
+func loadCredential(cfg *config.Config) (clientCredential, error) {
+	switch {
+	case cfg.ClientSecret != "":
+		return secretCredential(cfg.ClientSecret), nil
+	case cfg.ClientSecretFile != "":
+		data, err := os.ReadFile(cfg.ClientSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client secret: %w", err)
+		}
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return nil, fmt.Errorf("client secret file %s is empty", cfg.ClientSecretFile)
+		}
+		return secretCredential(secret), nil
+	case cfg.ClientCertificate != "":
+		return loadCertificate(cfg.ClientID, cfg.ClientCertificate, cfg.ClientKey)
+	case cfg.FederatedToken != "" || cfg.FederatedTokenFile != "":
+		return federatedCredential{token: cfg.FederatedToken, file: cfg.FederatedTokenFile}, nil
+	}
+	return nil, fmt.Errorf("no app credentials configured — set TCLI_CLIENT_SECRET, TCLI_CLIENT_SECRET_FILE, TCLI_CLIENT_CERTIFICATE or TCLI_FEDERATED_TOKEN_FILE")
+}
+
+func (s *AppTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.AccessToken != "" && !s.token.IsExpired() {
+		return s.token.AccessToken, nil
+	}
+
+	values := url.Values{
+		"client_id":  {s.cfg.ClientID},
+		"grant_type": {"client_credentials"},
+		// App-only tokens carry every application permission granted to
+		// the app; individual scopes cannot be requested.
+		"scope": {s.cfg.Scope(".default")},
+	}
+	if err := s.cred.apply(values, tokenEndpoint(s.cfg)); err != nil {
+		return "", err
+	}
+	Logger.Info("requesting app-only token", "client_id", s.cfg.ClientID)
+	tok, err := postToken(s.cfg, values)
+	if err != nil {
+		return "", fmt.Errorf("requesting app token: %w", err)
+	}
+	if tok.Error != "" {
+		return "", fmt.Errorf("%w (HTTP %d): %s", ErrCredentialsRejected, tok.Status, tok.ErrorDesc)
+	}
+
+	s.token = TokenCache{
+		AccessToken: tok.AccessToken,
+		ExpiresAt:   time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second),
+	}
+	return s.token.AccessToken, nil
+}
+
+type secretCredential string
+
+func (s secretCredential) apply(values url.Values, audience string) error {
+	values.Set("client_secret", string(s))
+	return nil
+}
+
+// federatedCredential presents an OIDC token issued by another identity
+// provider, such as GitHub Actions or a Kubernetes cluster, as the client
+// assertion. Entra ID accepts it when the app has a federated identity
+// credential matching the token's issuer and subject.
+type federatedCredential struct {
+	token string
+	// file is read on every use, as the token in it is rotated.
+	file string
+}
+
+func (f federatedCredential) apply(values url.Values, audience string) error {
+	token := f.token
+	if token == "" {
+		data, err := os.ReadFile(f.file)
+		if err != nil {
+			return fmt.Errorf("reading federated token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+		if token == "" {
+			return fmt.Errorf("federated token file %s is empty", f.file)
+		}
+	}
+	values.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	values.Set("client_assertion", token)
+	return nil
+}
+
+// certificateCredential proves possession of the private key of a
+// certificate registered for the app by signing a JWT client assertion.
+type certificateCredential struct {
+	clientID string
+	// thumbprint is the SHA-1 hash of the DER certificate, which Entra ID
+	// uses to find the registered certificate.
+	thumbprint []byte
+	key        *rsa.PrivateKey
+}
+
+func (c certificateCredential) apply(values url.Values, audience string) error {
+	assertion, err := c.assertion(audience, time.Now())
+	if err != nil {
+		return err
+	}
+	values.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	values.Set("client_assertion", assertion)
+	return nil
+}
+
+// assertion returns a JWT signed with RS256, valid for ten minutes.
+func (c certificateCredential) assertion(audience string, now time.Time) (string, error) {
+	var jti [16]byte
+	rand.Read(jti[:])
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(c.thumbprint),
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"iss": c.clientID,
+		"sub": c.clientID,
+		"jti": hex.EncodeToString(jti[:]),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("signing client assertion: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// loadCertificate reads a PEM certificate and its RSA private key, from
+// keyFile when given and otherwise from the certificate file.
+func loadCertificate(clientID, certFile, keyFile string) (*certificateCredential, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client certificate: %w", err)
+	}
+	if keyFile != "" {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client key: %w", err)
+		}
+		data = append(append(data, '\n'), key...)
+	}
+
+	var cert *x509.Certificate
+	var key *rsa.PrivateKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			if cert == nil {
+				if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+					return nil, fmt.Errorf("parsing client certificate: %w", err)
+				}
+			}
+		case "RSA PRIVATE KEY":
+			if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("parsing client key: %w", err)
+			}
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parsing client key: %w", err)
+			}
+			rsaKey, ok := parsed.(*rsa.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("client key must be an RSA key, got %T", parsed)
+			}
+			key = rsaKey
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, fmt.Errorf("encrypted client keys are not supported — decrypt it with: openssl pkcs8 -in key.pem -out key-plain.pem")
+		}
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate found in %s", certFile)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no private key found — include it in %s or set TCLI_CLIENT_KEY", certFile)
+	}
+	if pub, ok := cert.PublicKey.(*rsa.PublicKey); !ok || !pub.Equal(&key.PublicKey) {
+		return nil, fmt.Errorf("client key does not match the certificate in %s", certFile)
+	}
+
+	thumbprint := sha1.Sum(cert.Raw)
+	return &certificateCredential{clientID: clientID, thumbprint: thumbprint[:], key: key}, nil
+}
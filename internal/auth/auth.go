@@ -3,17 +3,33 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/piotrwolkowski/tcli/config"
 )
 
-// Scopes requested. offline_access is required to receive a refresh token.
-const graphScopes = "https://graph.microsoft.com/Chat.Read https://graph.microsoft.com/ChatMessage.Send offline_access"
+// defaultPermissions are requested at sign-in. Others, such as
+// Files.ReadWrite to upload attachments, are added with RequestPermission when
+// first needed, so tenants that only consented to these keep working.
+var defaultPermissions = []string{"Chat.Read", "ChatMessage.Send"}
+
+// graphScopes returns the scopes for perms, qualified with the Graph host of
+// the configured cloud. offline_access is required to receive a refresh token.
+func graphScopes(cfg *config.Config, perms []string) string {
+	var scopes []string
+	for _, p := range perms {
+		scopes = append(scopes, cfg.Scope(p))
+	}
+	return strings.Join(append(scopes, "offline_access"), " ")
+}
 
 type deviceCodeResponse struct {
 	DeviceCode      string `json:"device_code"`
@@ -32,27 +48,63 @@ type tokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 	Error        string `json:"error"`
 	ErrorDesc    string `json:"error_description"`
+	// Status is the HTTP status of the token response.
+	Status int `json:"-"`
 }
 
-func deviceCodeEndpoint(tenantID string) string {
-	return "https://login.microsoftonline.com/" + tenantID + "/oauth2/v2.0/devicecode"
+// Errors returned by GetToken when a new sign-in is needed. Test for them
+// with errors.Is.
+var (
+	ErrNotLoggedIn    = errors.New("not logged in — run: tcli login")
+	ErrSessionExpired = errors.New("session expired — run: tcli login")
+)
+
+// ErrUnavailable is returned when the token endpoint could not be reached or
+// failed with a server error. Signing in may succeed if retried later.
+var ErrUnavailable = errors.New("sign-in service unavailable")
+
+// ErrConsentRequired is returned by RequestPermission when the permission has
+// not been granted to tcli for the signed-in user.
+var ErrConsentRequired = errors.New("permission not granted")
+
+// HTTPClient sends all sign-in requests. Replace its transport to trace or
+// proxy them.
+var HTTPClient = &http.Client{}
+
+// Logger receives sign-in and token refresh events. It discards them unless
+// replaced.
+var Logger = slog.New(slog.DiscardHandler)
+
+func deviceCodeEndpoint(cfg *config.Config) string {
+	return cfg.Authority() + "/" + cfg.TenantID + "/oauth2/v2.0/devicecode"
 }
 
-func tokenEndpoint(tenantID string) string {
-	return "https://login.microsoftonline.com/" + tenantID + "/oauth2/v2.0/token"
+func tokenEndpoint(cfg *config.Config) string {
+	return cfg.Authority() + "/" + cfg.TenantID + "/oauth2/v2.0/token"
 }
 
 // Login performs the OAuth2 device code flow and caches the resulting tokens.
-func Login(ctx context.Context) error {
+// It asks for the default permissions, those added to the previous sign-in
+// with RequestPermission, and permissions, e.g. "Files.ReadWrite".
+func Login(ctx context.Context, permissions ...string) error {
 	cfg, err := config.Load()
 	if err != nil {
 		return err
 	}
+	perms := slices.Clone(defaultPermissions)
+	if prev, err := LoadCache(); err == nil && prev != nil {
+		perms = prev.permissions()
+	}
+	for _, p := range permissions {
+		if !slices.Contains(perms, p) {
+			perms = append(perms, p)
+		}
+	}
 
 	// Step 1: request a device code.
-	resp, err := http.PostForm(deviceCodeEndpoint(cfg.TenantID), url.Values{
+	resp, err := HTTPClient.PostForm(deviceCodeEndpoint(cfg), url.Values{
 		"client_id": {cfg.ClientID},
-		"scope":     {graphScopes},
+		"scope":     {graphScopes(cfg, perms)},
 	})
 	if err != nil {
 		return fmt.Errorf("requesting device code: %w", err)
@@ -65,10 +117,10 @@ func Login(ctx context.Context) error {
 		return fmt.Errorf("parsing device code response: %w", err)
 	}
 	if dcResp.Error != "" {
-		return fmt.Errorf("device code request failed: %s", dcResp.ErrorDesc)
+		return fmt.Errorf("device code request failed (HTTP %d): %s", resp.StatusCode, dcResp.ErrorDesc)
 	}
 	if dcResp.DeviceCode == "" {
-		return fmt.Errorf("unexpected device code response: %s", string(body))
+		return fmt.Errorf("unexpected device code response (HTTP %d): %s", resp.StatusCode, string(body))
 	}
 
 	fmt.Println(dcResp.Message)
@@ -79,6 +131,7 @@ func Login(ctx context.Context) error {
 		interval = 5
 	}
 	deadline := time.Now().Add(time.Duration(dcResp.ExpiresIn) * time.Second)
+	var lastErr error
 
 	for time.Now().Before(deadline) {
 		select {
@@ -87,38 +140,52 @@ func Login(ctx context.Context) error {
 		case <-time.After(time.Duration(interval) * time.Second):
 		}
 
-		tok, err := postToken(cfg.TenantID, url.Values{
+		tok, err := postToken(cfg, url.Values{
 			"client_id":   {cfg.ClientID},
 			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
 			"device_code": {dcResp.DeviceCode},
 		})
 		if err != nil {
+			lastErr = err // keep polling through transient failures
+			Logger.Warn("polling for sign-in failed", "error", err)
 			continue
 		}
 		switch tok.Error {
 		case "authorization_pending":
+			Logger.Debug("waiting for device code sign-in")
 			continue
 		case "slow_down":
 			interval += 5
+			Logger.Info("sign-in server asked to poll less often", "interval_seconds", interval)
 			continue
 		case "":
 			// success
 		default:
-			return fmt.Errorf("authentication failed: %s", tok.ErrorDesc)
+			return fmt.Errorf("authentication failed (HTTP %d): %s", tok.Status, tok.ErrorDesc)
 		}
 
 		cache := &TokenCache{
 			AccessToken:  tok.AccessToken,
 			RefreshToken: tok.RefreshToken,
 			ExpiresAt:    time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second),
+			Permissions:  perms,
+		}
+		release, err := lockCache(ctx)
+		if err != nil {
+			return fmt.Errorf("saving token: %w", err)
 		}
-		if err := SaveCache(cache); err != nil {
+		err = SaveCache(cache)
+		release()
+		if err != nil {
 			return fmt.Errorf("saving token: %w", err)
 		}
 		fmt.Println("Login successful.")
 		return nil
 	}
 
+	if lastErr != nil {
+		return fmt.Errorf("login timed out — device code expired (last error: %v), run: tcli login", lastErr)
+	}
 	return fmt.Errorf("login timed out — device code expired, run: tcli login")
 }
 
@@ -131,15 +198,34 @@ func GetToken(ctx context.Context) (string, error) {
 		return "", err
 	}
 	if cache == nil {
-		return "", fmt.Errorf("not logged in — run: tcli login")
+		return "", ErrNotLoggedIn
 	}
 
 	if !cache.IsExpired() {
 		return cache.AccessToken, nil
 	}
 
+	// Only one process refreshes: the others wait for the lock and then find
+	// the refreshed token in the cache. Refreshing twice would fail anyway
+	// when the first refresh rotates the refresh token.
+	release, err := lockCache(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	if cache, err = LoadCache(); err != nil {
+		return "", err
+	}
+	if cache == nil {
+		return "", ErrNotLoggedIn
+	}
+	if !cache.IsExpired() {
+		Logger.Debug("using token refreshed by another process", "expires_at", cache.ExpiresAt)
+		return cache.AccessToken, nil
+	}
+
 	if cache.RefreshToken == "" {
-		return "", fmt.Errorf("session expired — run: tcli login")
+		return "", ErrSessionExpired
 	}
 
 	cfg, err := config.Load()
@@ -147,14 +233,20 @@ func GetToken(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	tok, err := postToken(cfg.TenantID, url.Values{
+	Logger.Info("access token expired, refreshing", "expired_at", cache.ExpiresAt)
+	tok, err := postToken(cfg, url.Values{
 		"client_id":     {cfg.ClientID},
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {cache.RefreshToken},
-		"scope":         {graphScopes},
+		"scope":         {graphScopes(cfg, cache.permissions())},
 	})
-	if err != nil || tok.Error != "" {
-		return "", fmt.Errorf("session expired — run: tcli login")
+	if err != nil {
+		Logger.Warn("token refresh failed", "error", err)
+		return "", fmt.Errorf("refreshing access token: %w", err)
+	}
+	if tok.Error != "" {
+		Logger.Warn("refresh token rejected", "status", tok.Status, "error", tok.Error, "description", tok.ErrorDesc)
+		return "", ErrSessionExpired
 	}
 
 	cache.AccessToken = tok.AccessToken
@@ -162,22 +254,127 @@ func GetToken(ctx context.Context) (string, error) {
 		cache.RefreshToken = tok.RefreshToken // servers may rotate refresh tokens
 	}
 	cache.ExpiresAt = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
-	_ = SaveCache(cache)
+	if err := SaveCache(cache); err != nil {
+		Logger.Warn("could not save refreshed token", "error", err)
+	}
+	Logger.Info("access token refreshed", "expires_at", cache.ExpiresAt, "refresh_token_rotated", tok.RefreshToken != "")
 
 	return cache.AccessToken, nil
 }
 
-func postToken(tenantID string, values url.Values) (*tokenResponse, error) {
-	resp, err := http.PostForm(tokenEndpoint(tenantID), values)
+// RequestPermission extends the cached sign-in to permission, e.g.
+// "Files.ReadWrite", by refreshing the token with it added to the scopes. It
+// does nothing if the permission was already granted. If the user or an admin
+// has not consented to it, the error wraps ErrConsentRequired and the cached
+// sign-in is kept unchanged.
+func RequestPermission(ctx context.Context, permission string) error {
+	cache, err := LoadCache()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if cache == nil {
+		return ErrNotLoggedIn
+	}
+	if slices.Contains(cache.permissions(), permission) {
+		return nil
+	}
+
+	release, err := lockCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if cache, err = LoadCache(); err != nil {
+		return err
+	}
+	if cache == nil {
+		return ErrNotLoggedIn
+	}
+	perms := cache.permissions()
+	if slices.Contains(perms, permission) {
+		return nil
+	}
+	if cache.RefreshToken == "" {
+		return ErrSessionExpired
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	perms = append(slices.Clone(perms), permission)
+	Logger.Info("requesting additional permission", "permission", permission)
+	tok, err := postToken(cfg, url.Values{
+		"client_id":     {cfg.ClientID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {cache.RefreshToken},
+		"scope":         {graphScopes(cfg, perms)},
+	})
+	if err != nil {
+		return fmt.Errorf("requesting %s: %w", permission, err)
+	}
+	if tok.Error != "" {
+		Logger.Warn("additional permission refused", "permission", permission, "status", tok.Status, "error", tok.Error, "description", tok.ErrorDesc)
+		return fmt.Errorf("%w: %s — add it to the app registration, grant consent and run: tcli login --permission %s", ErrConsentRequired, permission, permission)
+	}
+
+	cache.AccessToken = tok.AccessToken
+	if tok.RefreshToken != "" {
+		cache.RefreshToken = tok.RefreshToken
+	}
+	cache.ExpiresAt = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	cache.Permissions = perms
+	if err := SaveCache(cache); err != nil {
+		return fmt.Errorf("saving token: %w", err)
+	}
+	return nil
+}
+
+// UserTokenSource supplies tokens for the user signed in with tcli login,
+// refreshing them as needed. It satisfies graph.TokenSource and
+// graph.PermissionRequester.
+type UserTokenSource struct{}
+
+func (UserTokenSource) Token(ctx context.Context) (string, error) {
+	return GetToken(ctx)
+}
+
+func (UserTokenSource) RequestPermission(ctx context.Context, permission string) error {
+	return RequestPermission(ctx, permission)
+}
+
+// postToken calls the token endpoint. OAuth errors such as
+// authorization_pending are returned in the response's Error field; an error
+// is only returned when no OAuth response was received.
+func postToken(cfg *config.Config, values url.Values) (*tokenResponse, error) {
+	resp, err := HTTPClient.PostForm(tokenEndpoint(cfg), values)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading token response: %w", ErrUnavailable, err)
+	}
 
-	var tok tokenResponse
+	tok := tokenResponse{Status: resp.StatusCode}
 	if err := json.Unmarshal(body, &tok); err != nil {
-		return nil, fmt.Errorf("parsing token response: %w", err)
+		err := fmt.Errorf("token endpoint returned HTTP %d: %s", resp.StatusCode, truncate(string(body), 200))
+		if resp.StatusCode >= 500 {
+			err = fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		return nil, err
+	}
+	if tok.Error == "" && tok.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned HTTP %d without a token", resp.StatusCode)
 	}
 	return &tok, nil
 }
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "…"
+	}
+	return s
+}
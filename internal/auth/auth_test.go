@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/graphtest"
+)
+
+// useFake points sign-in at a fresh fake identity server and isolates the
+// token cache in a temporary home directory.
+func useFake(t *testing.T) *graphtest.Server {
+	t.Helper()
+	srv := graphtest.NewServer(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TCLI_CLIENT_ID", "client")
+	t.Setenv("TCLI_TENANT_ID", "tenant")
+	t.Setenv("TCLI_AUTHORITY_URL", srv.AuthorityURL())
+	return srv
+}
+
+func TestLoginAndGetToken(t *testing.T) {
+	srv := useFake(t)
+	srv.SetPendingPolls(1)
+	ctx := context.Background()
+
+	if _, err := GetToken(ctx); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("GetToken() before login = %v, want not logged in", err)
+	}
+
+	if err := Login(ctx); err != nil {
+		t.Fatalf("Login() = %v", err)
+	}
+	cache, err := LoadCache()
+	if err != nil || cache == nil || cache.AccessToken == "" || cache.RefreshToken == "" {
+		t.Fatalf("cache after login = %+v, %v", cache, err)
+	}
+
+	tok, err := GetToken(ctx)
+	if err != nil || tok != cache.AccessToken {
+		t.Errorf("GetToken() = %q, %v; want cached %q", tok, err, cache.AccessToken)
+	}
+}
+
+func TestGetTokenRefreshes(t *testing.T) {
+	srv := useFake(t)
+	ctx := context.Background()
+	if err := Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, _ := LoadCache()
+	old := *cache
+	cache.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := SaveCache(cache); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := GetToken(ctx)
+	if err != nil {
+		t.Fatalf("GetToken() = %v", err)
+	}
+	if tok == old.AccessToken {
+		t.Error("GetToken() returned the expired access token")
+	}
+	cache, _ = LoadCache()
+	if cache.RefreshToken == old.RefreshToken {
+		t.Error("rotated refresh token was not saved")
+	}
+
+	srv.RevokeTokens()
+	cache.ExpiresAt = time.Now().Add(-time.Minute)
+	SaveCache(cache)
+	if _, err := GetToken(ctx); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("GetToken() with revoked refresh token = %v, want session expired", err)
+	}
+}
+
+func TestRefreshReportsHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, "<html>Bad Gateway</html>")
+	}))
+	defer srv.Close()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TCLI_CLIENT_ID", "client")
+	t.Setenv("TCLI_TENANT_ID", "tenant")
+	t.Setenv("TCLI_AUTHORITY_URL", srv.URL)
+	SaveCache(&TokenCache{AccessToken: "old", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour)})
+
+	_, err := GetToken(context.Background())
+	if !errors.Is(err, ErrUnavailable) || !strings.Contains(err.Error(), "HTTP 502") {
+		t.Errorf("GetToken() = %v, want the HTTP status of the failed refresh", err)
+	}
+}
+
+func TestRefreshUsesCloudScopes(t *testing.T) {
+	var scope string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		scope = r.Form.Get("scope")
+		io.WriteString(w, `{"access_token":"new","expires_in":3600}`)
+	}))
+	defer srv.Close()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TCLI_CLIENT_ID", "client")
+	t.Setenv("TCLI_TENANT_ID", "tenant")
+	t.Setenv("TCLI_CLOUD", "gcc-high")
+	t.Setenv("TCLI_AUTHORITY_URL", srv.URL)
+	SaveCache(&TokenCache{AccessToken: "old", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour)})
+
+	if _, err := GetToken(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "https://graph.microsoft.us/Chat.Read https://graph.microsoft.us/ChatMessage.Send offline_access"
+	if scope != want {
+		t.Errorf("scope = %q, want %q", scope, want)
+	}
+
+	// A refresh keeps the permissions recorded in the cache.
+	SaveCache(&TokenCache{AccessToken: "old", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour),
+		Permissions: []string{"Chat.Read", "ChatMessage.Send", "Files.ReadWrite"}})
+	if _, err := GetToken(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want = "https://graph.microsoft.us/Chat.Read https://graph.microsoft.us/ChatMessage.Send https://graph.microsoft.us/Files.ReadWrite offline_access"
+	if scope != want {
+		t.Errorf("scope with recorded permissions = %q, want %q", scope, want)
+	}
+}
+
+func TestRequestPermission(t *testing.T) {
+	srv := useFake(t)
+	srv.RestrictPermissions("Chat.Read", "ChatMessage.Send", "Files.ReadWrite")
+	ctx := context.Background()
+	if err := Login(ctx); err != nil {
+		t.Fatalf("Login() with the default permissions = %v", err)
+	}
+	before, _ := LoadCache()
+
+	if err := RequestPermission(ctx, "Files.ReadWrite"); err != nil {
+		t.Fatalf("RequestPermission() = %v", err)
+	}
+	cache, _ := LoadCache()
+	if cache.AccessToken == before.AccessToken || !slices.Contains(cache.Permissions, "Files.ReadWrite") {
+		t.Errorf("cache after RequestPermission() = %+v, want a new token with Files.ReadWrite", cache)
+	}
+
+	err := RequestPermission(ctx, "Chat.ReadWrite")
+	if !errors.Is(err, ErrConsentRequired) || !strings.Contains(err.Error(), "tcli login --permission Chat.ReadWrite") {
+		t.Errorf("RequestPermission() without consent = %v, want ErrConsentRequired", err)
+	}
+	if after, _ := LoadCache(); after.AccessToken != cache.AccessToken || slices.Contains(after.Permissions, "Chat.ReadWrite") {
+		t.Errorf("refused permission changed the cache: %+v", after)
+	}
+	if err := Login(ctx, "Chat.ReadWrite"); err == nil {
+		t.Error("Login() with an unconsented permission succeeded")
+	}
+}
+
+func TestConcurrentGetTokenRefreshesOnce(t *testing.T) {
+	srv := useFake(t)
+	ctx := context.Background()
+	if err := Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cache, _ := LoadCache()
+	cache.ExpiresAt = time.Now().Add(-time.Minute)
+	SaveCache(cache)
+	before := len(srv.Requests())
+
+	// The fake server rotates refresh tokens, so a second refresh with the
+	// old one would be rejected.
+	const n = 8
+	tokens := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Go(func() { tokens[i], errs[i] = GetToken(ctx) })
+	}
+	wg.Wait()
+
+	for i := range n {
+		if errs[i] != nil || tokens[i] != tokens[0] {
+			t.Errorf("GetToken() #%d = %q, %v; want %q", i, tokens[i], errs[i], tokens[0])
+		}
+	}
+	if refreshes := len(srv.Requests()) - before; refreshes != 1 {
+		t.Errorf("made %d token requests, want 1", refreshes)
+	}
+}
+
+func TestRefreshUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TCLI_CLIENT_ID", "client")
+	t.Setenv("TCLI_TENANT_ID", "tenant")
+	t.Setenv("TCLI_AUTHORITY_URL", srv.URL)
+	SaveCache(&TokenCache{AccessToken: "old", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour)})
+
+	if _, err := GetToken(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("GetToken() with an unreachable authority = %v, want ErrUnavailable", err)
+	}
+}
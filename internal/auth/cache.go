@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,18 +10,30 @@ import (
 	"time"
 
 	"github.com/piotrwolkowski/tcli/config"
+	"github.com/piotrwolkowski/tcli/internal/filelock"
 )
 
 type TokenCache struct {
 	AccessToken  string    `json:"accessToken"`
 	RefreshToken string    `json:"refreshToken"`
 	ExpiresAt    time.Time `json:"expiresAt"`
+	// Permissions are the Graph permissions the tokens were issued for.
+	// Caches written before they were recorded have the default ones.
+	Permissions []string `json:"permissions,omitempty"`
 }
 
 func (t *TokenCache) IsExpired() bool {
 	return time.Now().After(t.ExpiresAt.Add(-2 * time.Minute))
 }
 
+// permissions returns the permissions to ask for when refreshing the tokens.
+func (t *TokenCache) permissions() []string {
+	if len(t.Permissions) == 0 {
+		return defaultPermissions
+	}
+	return t.Permissions
+}
+
 func cachePath() (string, error) {
 	dir, err := config.Dir()
 	if err != nil {
@@ -29,6 +42,32 @@ func cachePath() (string, error) {
 	return filepath.Join(dir, "tokens.json"), nil
 }
 
+// lockTimeout bounds how long to wait for another tcli process to finish
+// with the token cache.
+var lockTimeout = 30 * time.Second
+
+// lockCache takes an exclusive lock on the token cache, shared by every tcli
+// process of the user, and returns a function that releases it. It waits
+// while another process holds the lock, e.g. during a token refresh.
+func lockCache(ctx context.Context) (release func(), err error) {
+	p, err := cachePath()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	// The lock is on a separate file because tokens.json is replaced, not
+	// rewritten, on every save.
+	release, err = filelock.Lock(ctx, p+".lock")
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("token cache is locked by another tcli process (%s): %w", p+".lock", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking token cache: %w", err)
+	}
+	return release, nil
+}
+
 func LoadCache() (*TokenCache, error) {
 	p, err := cachePath()
 	if err != nil {
@@ -50,6 +89,8 @@ func LoadCache() (*TokenCache, error) {
 	return &cache, nil
 }
 
+// SaveCache writes the cache to a temporary file and renames it into place,
+// so readers never see a partly written cache.
 func SaveCache(cache *TokenCache) error {
 	p, err := cachePath()
 	if err != nil {
@@ -64,7 +105,27 @@ func SaveCache(cache *TokenCache) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(p, data, 0600)
+	// CreateTemp makes the file readable by the owner only.
+	f, err := os.CreateTemp(filepath.Dir(p), "tokens-*.json.tmp")
+	if err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+	return nil
 }
 
 func ClearCache() error {
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -43,3 +46,50 @@ func TestIsExpired(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveCacheReplacesFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, tok := range []string{"first", "second"} {
+		if err := SaveCache(&TokenCache{AccessToken: tok}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache, err := LoadCache()
+	if err != nil || cache.AccessToken != "second" {
+		t.Fatalf("LoadCache() = %+v, %v; want the last saved token", cache, err)
+	}
+
+	p, _ := cachePath()
+	entries, _ := os.ReadDir(filepath.Dir(p))
+	for _, e := range entries {
+		if e.Name() != "tokens.json" {
+			t.Errorf("left %s behind in the cache dir", e.Name())
+		}
+	}
+	if info, err := os.Stat(p); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("cache file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestLockCacheWaitsForRelease(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	release, err := lockCache(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := lockTimeout
+	lockTimeout = 100 * time.Millisecond
+	defer func() { lockTimeout = timeout }()
+	if _, err := lockCache(ctx); err == nil {
+		t.Fatal("lockCache() succeeded while the lock was held")
+	}
+
+	release()
+	release, err = lockCache(ctx)
+	if err != nil {
+		t.Fatalf("lockCache() after release = %v", err)
+	}
+	release()
+}
@@ -0,0 +1,157 @@
+// Package dedupe remembers which messages have already been sent, so a
+// retried "tcli send" (e.g. a re-run CI job) does not post the same message
+// twice. Each sent key is stored as its own small file named after a hash of
+// the chat and key.
+package dedupe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/filelock"
+)
+
+// Retention is how long records are kept before they are pruned.
+const Retention = 7 * 24 * time.Hour
+
+// Record is a message sent under an idempotency key.
+type Record struct {
+	Key         string    `json:"key"`
+	ChatID      string    `json:"chatId"`
+	MessageID   string    `json:"messageId"`
+	CreatedAt   string    `json:"createdDateTime"`
+	ContentHash string    `json:"contentHash"`
+	SentAt      time.Time `json:"sentAt"`
+}
+
+// Changed reports whether content differs from what was sent.
+func (r Record) Changed(content string) bool {
+	return r.ContentHash != Hash(content)
+}
+
+// Hash returns the hex SHA-256 of content.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// AutoKey derives a key from the message content, for deduplicating
+// identical messages without an explicit key.
+func AutoKey(content string) string {
+	return "content:" + Hash(content)
+}
+
+// Store is a directory of sent records.
+type Store struct {
+	dir string
+}
+
+// NewStore returns the store kept in dir. The directory is created on first use.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Lock takes an exclusive lock on key in chatID, shared by every tcli process
+// of the user, and returns a function that releases it. Hold it from Lookup
+// until the message is posted and saved, so that two processes sending with
+// the same key cannot both post. It waits while another process holds the
+// lock, until ctx is done.
+func (s *Store) Lock(ctx context.Context, chatID, key string) (release func(), err error) {
+	p := strings.TrimSuffix(s.path(chatID, key), ".json") + ".lock"
+	release, err = filelock.Lock(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("locking sent record: %w", err)
+	}
+	// Lock files are pruned like records; a fresh time keeps this one.
+	now := time.Now()
+	os.Chtimes(p, now, now)
+	return release, nil
+}
+
+// Lookup returns the record for key in chatID, or nil if there is none or it
+// is older than maxAge. A maxAge of zero or less means Retention.
+func (s *Store) Lookup(chatID, key string, maxAge time.Duration) (*Record, error) {
+	if maxAge <= 0 {
+		maxAge = Retention
+	}
+	data, err := os.ReadFile(s.path(chatID, key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading sent record: %w", err)
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		// A corrupt record is treated as missing rather than blocking sends.
+		return nil, nil
+	}
+	if rec.ChatID != chatID || rec.Key != key || time.Since(rec.SentAt) > maxAge {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+// Save records a sent message, replacing any earlier record for the same
+// chat and key, and prunes records past Retention.
+func (s *Store) Save(rec Record) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("creating sent records dir: %w", err)
+	}
+	if rec.SentAt.IsZero() {
+		rec.SentAt = time.Now()
+	}
+	rec.SentAt = rec.SentAt.UTC()
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("writing sent record: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing sent record: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing sent record: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(rec.ChatID, rec.Key)); err != nil {
+		return fmt.Errorf("writing sent record: %w", err)
+	}
+
+	s.prune(time.Now().Add(-Retention))
+	return nil
+}
+
+// prune removes records and lock files last written before cutoff. Errors
+// are ignored; pruning is retried on the next Save.
+func (s *Store) prune(cutoff time.Time) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") && !strings.HasSuffix(e.Name(), ".lock") {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(s.dir, e.Name()))
+		}
+	}
+}
+
+func (s *Store) path(chatID, key string) string {
+	return filepath.Join(s.dir, Hash(chatID + "\n" + key)[:32]+".json")
+}
@@ -0,0 +1,121 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSaveLookup(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	if rec, err := s.Lookup("chat", "build-42", 0); err != nil || rec != nil {
+		t.Fatalf("Lookup() on empty store = %+v, %v; want nil", rec, err)
+	}
+
+	if err := s.Save(Record{Key: "build-42", ChatID: "chat", MessageID: "m1", ContentHash: Hash("passed")}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := s.Lookup("chat", "build-42", 0)
+	if err != nil || rec == nil || rec.MessageID != "m1" {
+		t.Fatalf("Lookup() = %+v, %v; want m1", rec, err)
+	}
+	if rec.Changed("passed") {
+		t.Error("Changed() with same content = true")
+	}
+	if !rec.Changed("failed") {
+		t.Error("Changed() with new content = false")
+	}
+
+	if rec, _ := s.Lookup("other-chat", "build-42", 0); rec != nil {
+		t.Errorf("key leaked across chats: %+v", rec)
+	}
+}
+
+func TestLookupMaxAge(t *testing.T) {
+	s := NewStore(t.TempDir())
+	key := AutoKey("hello")
+	if err := s.Save(Record{Key: key, ChatID: "chat", MessageID: "m1", SentAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, _ := s.Lookup("chat", key, 2*time.Hour); rec == nil {
+		t.Error("record inside the window not found")
+	}
+	if rec, _ := s.Lookup("chat", key, 30*time.Minute); rec != nil {
+		t.Error("record outside the window found")
+	}
+}
+
+func TestSavePrunesOldRecords(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	if err := s.Save(Record{Key: "old", ChatID: "chat", MessageID: "m1"}); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-Retention - time.Hour)
+	if err := os.Chtimes(s.path("chat", "old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Save(Record{Key: "new", ChatID: "chat", MessageID: "m2"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(s.path("chat", "new")) {
+		t.Errorf("after prune dir has %v, want only the new record", entries)
+	}
+}
+
+func TestLockSerialisesSends(t *testing.T) {
+	s := NewStore(t.TempDir())
+	ctx := context.Background()
+
+	// Each sender posts only if no record exists, as tcli send does.
+	var posted atomic.Int32
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			release, err := s.Lock(ctx, "chat", "deploy-1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			if rec, _ := s.Lookup("chat", "deploy-1", 0); rec != nil {
+				return
+			}
+			posted.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			if err := s.Save(Record{Key: "deploy-1", ChatID: "chat", MessageID: fmt.Sprint(i)}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := posted.Load(); n != 1 {
+		t.Errorf("%d senders posted under the same key, want 1", n)
+	}
+
+	release, err := s.Lock(ctx, "chat", "deploy-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := s.Lock(short, "chat", "deploy-1"); err == nil {
+		t.Error("Lock() succeeded while the key was locked")
+	}
+	other, err := s.Lock(short, "chat", "deploy-2")
+	if err != nil {
+		t.Fatalf("Lock() on another key = %v", err)
+	}
+	other()
+}
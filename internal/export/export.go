@@ -0,0 +1,314 @@
+// Package export renders chat history for archiving.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/markup"
+)
+
+// Supported export formats.
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatMbox     = "mbox"
+)
+
+// Formats lists the accepted format names.
+var Formats = []string{FormatJSON, FormatMarkdown, FormatHTML, FormatMbox}
+
+// Meta describes the exported chat.
+type Meta struct {
+	ChatID     string    `json:"chatId"`
+	ChatName   string    `json:"chatName"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Since      time.Time `json:"since,omitzero"`
+	Until      time.Time `json:"until,omitzero"`
+}
+
+// ValidateFormat reports whether format is a supported export format.
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown export format %q (supported: %s)", format, strings.Join(Formats, ", "))
+}
+
+// Write renders msgs, which must be oldest first, in the given format.
+func Write(w io.Writer, format string, meta Meta, msgs []graph.Message) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, meta, msgs)
+	case FormatMarkdown:
+		return writeMarkdown(w, meta, msgs)
+	case FormatHTML:
+		return writeHTML(w, meta, msgs)
+	case FormatMbox:
+		return writeMbox(w, meta, msgs)
+	}
+	return ValidateFormat(format)
+}
+
+func writeJSON(w io.Writer, meta Meta, msgs []graph.Message) error {
+	if msgs == nil {
+		msgs = []graph.Message{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Meta
+		Messages []graph.Message `json:"messages"`
+	}{meta, msgs})
+}
+
+func writeMarkdown(w io.Writer, meta Meta, msgs []graph.Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", meta.ChatName)
+	fmt.Fprintf(&b, "- Chat ID: `%s`\n", meta.ChatID)
+	fmt.Fprintf(&b, "- Exported: %s\n", formatTime(meta.ExportedAt.Format(time.RFC3339)))
+	if !meta.Since.IsZero() || !meta.Until.IsZero() {
+		fmt.Fprintf(&b, "- Range: %s – %s\n", rangeEnd(meta.Since), rangeEnd(meta.Until))
+	}
+	fmt.Fprintf(&b, "- Messages: %d\n", len(msgs))
+
+	for _, m := range msgs {
+		fmt.Fprintf(&b, "\n---\n\n**%s** · %s", graph.SenderName(m), formatTime(m.CreatedAt))
+		if m.LastEditedAt != "" {
+			fmt.Fprintf(&b, " · _edited %s_", formatTime(m.LastEditedAt))
+		}
+		if isImportant(m) {
+			fmt.Fprintf(&b, " · **%s**", strings.ToUpper(m.Importance))
+		}
+		b.WriteString("\n\n")
+
+		if m.DeletedAt != "" {
+			fmt.Fprintf(&b, "_Message deleted %s_\n", formatTime(m.DeletedAt))
+			continue
+		}
+		for _, a := range m.Attachments {
+			if ref, ok := a.Reference(); ok {
+				fmt.Fprintf(&b, "> **%s:** %s\n\n", referenceSender(ref), strings.ReplaceAll(ref.MessagePreview, "\n", " "))
+			}
+		}
+		body := m.Body.Content
+		if m.Body.ContentType == "html" || m.Body.ContentType == "" {
+			body = markup.Markdown(body)
+		}
+		if body != "" {
+			b.WriteString(body + "\n")
+		}
+		if r := graph.ReactionSummary(m); r != "" {
+			fmt.Fprintf(&b, "\n%s\n", r)
+		}
+		for _, a := range m.Attachments {
+			if a.ContentURL != "" {
+				fmt.Fprintf(&b, "\n- 📎 [%s](%s)", attachmentName(a), a.ContentURL)
+			}
+		}
+		if hasLinks(m) {
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHTML(w io.Writer, meta Meta, msgs []graph.Message) error {
+	e := html.EscapeString
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	// Nothing in an archive needs to run or load, whatever a message holds.
+	b.WriteString("<meta http-equiv=\"Content-Security-Policy\" content=\"default-src 'none'; style-src 'unsafe-inline'\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", e(meta.ChatName))
+	b.WriteString(`<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+.msg { border-top: 1px solid #ddd; padding: .5em 0; }
+.meta { color: #666; font-size: .9em; }
+blockquote { border-left: 3px solid #ccc; margin: .5em 0; padding-left: .5em; color: #555; }
+</style>
+</head>
+<body>
+`)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<p class=\"meta\">Chat ID %s · exported %s · %d messages</p>\n",
+		e(meta.ChatName), e(meta.ChatID), e(formatTime(meta.ExportedAt.Format(time.RFC3339))), len(msgs))
+
+	for _, m := range msgs {
+		fmt.Fprintf(&b, "<div class=\"msg\" id=\"msg-%s\">\n<p class=\"meta\"><strong>%s</strong> · %s", e(m.ID), e(graph.SenderName(m)), e(formatTime(m.CreatedAt)))
+		if m.LastEditedAt != "" {
+			fmt.Fprintf(&b, " · <em>edited %s</em>", e(formatTime(m.LastEditedAt)))
+		}
+		if isImportant(m) {
+			fmt.Fprintf(&b, " · <strong>%s</strong>", e(strings.ToUpper(m.Importance)))
+		}
+		b.WriteString("</p>\n")
+		if m.DeletedAt != "" {
+			fmt.Fprintf(&b, "<p><em>Message deleted %s</em></p>\n</div>\n", e(formatTime(m.DeletedAt)))
+			continue
+		}
+		for _, a := range m.Attachments {
+			if ref, ok := a.Reference(); ok {
+				fmt.Fprintf(&b, "<blockquote><a href=\"#msg-%s\"><strong>%s</strong></a>: %s</blockquote>\n",
+					e(ref.MessageID), e(referenceSender(ref)), e(ref.MessagePreview))
+			}
+		}
+		if m.Body.ContentType == "text" {
+			fmt.Fprintf(&b, "<div>%s</div>\n", strings.ReplaceAll(e(m.Body.Content), "\n", "<br>"))
+		} else {
+			fmt.Fprintf(&b, "<div>%s</div>\n", markup.SafeHTML(m.Body.Content))
+		}
+		for _, a := range m.Attachments {
+			if a.ContentURL != "" {
+				fmt.Fprintf(&b, "<p>📎 <a href=\"%s\">%s</a></p>\n", e(a.ContentURL), e(attachmentName(a)))
+			}
+		}
+		if r := graph.ReactionSummary(m); r != "" {
+			fmt.Fprintf(&b, "<p class=\"meta\">%s</p>\n", e(r))
+		}
+		b.WriteString("</div>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMbox writes one RFC 5322 message per chat message in mboxrd format,
+// so the history can be opened in any mail client.
+func writeMbox(w io.Writer, meta Meta, msgs []graph.Message) error {
+	var b strings.Builder
+	subject := mimeHeader("Chat: " + meta.ChatName)
+	for _, m := range msgs {
+		created, err := time.Parse(time.RFC3339, m.CreatedAt)
+		if err != nil {
+			created = meta.ExportedAt
+		}
+		sender := graph.SenderName(m)
+		fmt.Fprintf(&b, "From tcli %s\n", created.UTC().Format(time.ANSIC))
+		fmt.Fprintf(&b, "From: %s <%s>\n", mimeHeader(sender), senderAddress(m))
+		fmt.Fprintf(&b, "Date: %s\n", created.Format(time.RFC1123Z))
+		fmt.Fprintf(&b, "Subject: %s\n", subject)
+		fmt.Fprintf(&b, "Message-ID: <%s@%s>\n", m.ID, mboxDomain(meta.ChatID))
+		for _, a := range m.Attachments {
+			if ref, ok := a.Reference(); ok {
+				fmt.Fprintf(&b, "In-Reply-To: <%s@%s>\n", ref.MessageID, mboxDomain(meta.ChatID))
+				break
+			}
+		}
+		if m.LastEditedAt != "" {
+			fmt.Fprintf(&b, "X-Teams-Edited: %s\n", m.LastEditedAt)
+		}
+		if m.DeletedAt != "" {
+			fmt.Fprintf(&b, "X-Teams-Deleted: %s\n", m.DeletedAt)
+		}
+		if r := graph.ReactionSummary(m); r != "" {
+			fmt.Fprintf(&b, "X-Teams-Reactions: %s\n", mimeHeader(r))
+		}
+		if isImportant(m) {
+			b.WriteString("Importance: high\n")
+		}
+		b.WriteString("MIME-Version: 1.0\n")
+
+		body := m.Body.Content
+		contentType := "text/html"
+		if m.Body.ContentType == "text" {
+			contentType = "text/plain"
+		}
+		var links []string
+		for _, a := range m.Attachments {
+			if a.ContentURL != "" {
+				links = append(links, attachmentName(a)+": "+a.ContentURL)
+			}
+		}
+		if len(links) > 0 {
+			if contentType == "text/html" {
+				for _, l := range links {
+					body += "<br>📎 " + html.EscapeString(l)
+				}
+			} else {
+				body += "\n\n" + strings.Join(links, "\n")
+			}
+		}
+		fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\n\n", contentType)
+
+		for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+			// mboxrd: quote any line that looks like a "From " separator.
+			if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+				line = ">" + line
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatTime(ts string) string {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ts
+	}
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+func rangeEnd(t time.Time) string {
+	if t.IsZero() {
+		return "…"
+	}
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+func referenceSender(ref graph.MessageReference) string {
+	return graph.SenderName(graph.Message{From: ref.MessageSender})
+}
+
+func isImportant(m graph.Message) bool {
+	return m.Importance == graph.ImportanceHigh || m.Importance == graph.ImportanceUrgent
+}
+
+func attachmentName(a graph.Attachment) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.ContentURL
+}
+
+func hasLinks(m graph.Message) bool {
+	for _, a := range m.Attachments {
+		if a.ContentURL != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func senderAddress(m graph.Message) string {
+	if m.From != nil && m.From.User != nil && m.From.User.ID != "" {
+		return m.From.User.ID + "@teams.invalid"
+	}
+	if m.From != nil && m.From.Application != nil && m.From.Application.ID != "" {
+		return m.From.Application.ID + "@apps.teams.invalid"
+	}
+	return "system@teams.invalid"
+}
+
+func mboxDomain(chatID string) string {
+	return strings.NewReplacer(":", "-", "@", ".").Replace(chatID)
+}
+
+// mimeHeader encodes non-ASCII header text as an RFC 2047 encoded word.
+func mimeHeader(s string) string {
+	return mime.QEncoding.Encode("utf-8", s)
+}
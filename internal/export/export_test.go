@@ -0,0 +1,196 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+)
+
+var testMeta = Meta{
+	ChatID:     "19:abc@thread.v2",
+	ChatName:   "Incident 42",
+	ExportedAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+}
+
+func testMessages() []graph.Message {
+	return []graph.Message{
+		{
+			ID:        "1",
+			CreatedAt: "2026-10-18T09:00:00Z",
+			From:      &graph.MessageFrom{User: &graph.Identity{ID: "u1", DisplayName: "Alice"}},
+			Body:      graph.MessageBody{ContentType: "html", Content: "<p>Pods are <b>crashing</b></p>"},
+			Attachments: []graph.Attachment{
+				{ID: "a", ContentType: "reference", ContentURL: "https://files.test/log.txt", Name: "log.txt"},
+			},
+		},
+		{
+			ID:           "2",
+			CreatedAt:    "2026-10-18T09:05:00Z",
+			LastEditedAt: "2026-10-18T09:06:00Z",
+			From:         &graph.MessageFrom{User: &graph.Identity{ID: "u2", DisplayName: "Bob"}},
+			Body:         graph.MessageBody{ContentType: "html", Content: "From the logs: OOM"},
+			Attachments: []graph.Attachment{
+				{ID: "1", ContentType: "messageReference", Content: `{"messageId":"1","messagePreview":"Pods are crashing","messageSender":{"user":{"displayName":"Alice"}}}`},
+			},
+		},
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatMarkdown, testMeta, testMessages()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# Incident 42",
+		"**Alice** · 2026-10-18 09:00 UTC",
+		"Pods are **crashing**",
+		"[log.txt](https://files.test/log.txt)",
+		"_edited 2026-10-18 09:06 UTC_",
+		"> **Alice:** Pods are crashing",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHTMLEscapesMetadata(t *testing.T) {
+	meta := testMeta
+	meta.ChatName = "<script>x</script>"
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatHTML, meta, testMessages()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Errorf("chat name was not escaped")
+	}
+	if !strings.Contains(buf.String(), `<a href="https://files.test/log.txt">log.txt</a>`) {
+		t.Errorf("attachment link missing:\n%s", buf.String())
+	}
+}
+
+func TestWriteHTMLSanitisesBodies(t *testing.T) {
+	msgs := testMessages()
+	msgs[1].Body.Content = `<p onclick="steal()">ok</p><img src=x onerror="steal()"><script>steal()</script>`
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatHTML, testMeta, msgs); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "steal") || !strings.Contains(out, "<p>ok</p>") || !strings.Contains(out, "<b>crashing</b>") {
+		t.Errorf("message bodies not sanitised:\n%s", out)
+	}
+	if !strings.Contains(out, `content="default-src 'none'`) {
+		t.Errorf("no Content-Security-Policy in:\n%s", out)
+	}
+}
+
+func TestWriteMbox(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatMbox, testMeta, testMessages()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "\nFrom tcli ") + boolInt(strings.HasPrefix(out, "From tcli ")); n != 2 {
+		t.Errorf("expected 2 mbox separators, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out, "\n>From the logs: OOM\n") {
+		t.Errorf("body line starting with From was not quoted:\n%s", out)
+	}
+	if !strings.Contains(out, "In-Reply-To: <1@19-abc.thread.v2>") {
+		t.Errorf("reply header missing:\n%s", out)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, testMeta, testMessages()); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		ChatID   string          `json:"chatId"`
+		Messages []graph.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ChatID != testMeta.ChatID || len(got.Messages) != 2 {
+		t.Errorf("got chatId %q with %d messages", got.ChatID, len(got.Messages))
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	if err := ValidateFormat("markdown"); err != nil {
+		t.Errorf("ValidateFormat(markdown) = %v", err)
+	}
+	if err := ValidateFormat("pdf"); err == nil {
+		t.Errorf("ValidateFormat(pdf) = nil, want error")
+	}
+}
+
+func TestJournalResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json.partial")
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	msgs := testMessages()
+
+	j, state, err := OpenJournal(path, "chat", since, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Resumed {
+		t.Fatal("new journal reported as resumed")
+	}
+	if err := j.Append(msgs[:1], "https://graph.test/next"); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	// Simulate a write torn by the interruption.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"messages":[{"id":`)
+	f.Close()
+
+	j, state, err = OpenJournal(path, "chat", since, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Resumed || state.Done || state.Next != "https://graph.test/next" || len(state.Messages) != 1 {
+		t.Fatalf("unexpected resumed state: %+v", state)
+	}
+	if err := j.Append(msgs[1:], ""); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	_, state, err = OpenJournal(path, "chat", since, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Done || len(state.Messages) != 2 {
+		t.Fatalf("expected completed journal with 2 messages, got %+v", state)
+	}
+
+	// A different export discards the journal.
+	_, state, err = OpenJournal(path, "other-chat", since, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Resumed {
+		t.Errorf("journal for another chat was resumed")
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,155 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+)
+
+// Journal records each fetched page of an export in an append-only file next
+// to the output, so an interrupted export can continue from the last page
+// instead of starting over.
+type Journal struct {
+	f *os.File
+}
+
+// JournalState is what a journal recorded before it was reopened.
+type JournalState struct {
+	Messages []graph.Message
+	// Next is the link for the next page; empty with Done unset means the
+	// first page has not been fetched yet.
+	Next string
+	// Done is set once the last page has been recorded.
+	Done bool
+	// Resumed reports whether any earlier progress was found.
+	Resumed bool
+}
+
+type journalHeader struct {
+	ChatID string    `json:"chatId"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+type journalPage struct {
+	Messages []graph.Message `json:"messages"`
+	Next     string          `json:"next"`
+	Done     bool            `json:"done"`
+}
+
+// OpenJournal opens the journal at path for an export of chatID between
+// since and until. Progress recorded for the same export is returned;
+// a journal left by a different export is discarded.
+func OpenJournal(path, chatID string, since, until time.Time) (*Journal, JournalState, error) {
+	want := journalHeader{ChatID: chatID, Since: since.UTC(), Until: until.UTC()}
+
+	state, valid, err := readJournal(path, want)
+	if err != nil {
+		return nil, JournalState{}, err
+	}
+
+	if state.Resumed {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, JournalState{}, fmt.Errorf("opening export journal: %w", err)
+		}
+		// Drop any torn trailing line so new pages start on a clean line.
+		if err := f.Truncate(valid); err == nil {
+			_, err = f.Seek(valid, 0)
+		}
+		if err != nil {
+			f.Close()
+			return nil, JournalState{}, fmt.Errorf("opening export journal: %w", err)
+		}
+		return &Journal{f: f}, state, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, JournalState{}, fmt.Errorf("creating export journal: %w", err)
+	}
+	j := &Journal{f: f}
+	if err := j.write(want); err != nil {
+		f.Close()
+		return nil, JournalState{}, err
+	}
+	return j, JournalState{}, nil
+}
+
+// readJournal returns the progress recorded at path for the export described
+// by want, and the size of the journal up to its last complete line.
+func readJournal(path string, want journalHeader) (JournalState, int64, error) {
+	var state JournalState
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, 0, nil
+		}
+		return state, 0, fmt.Errorf("reading export journal: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return state, 0, nil
+	}
+	var got journalHeader
+	if err := json.Unmarshal(line, &got); err != nil || got.ChatID != want.ChatID ||
+		!got.Since.Equal(want.Since) || !got.Until.Equal(want.Until) {
+		return state, 0, nil
+	}
+	valid := int64(len(line))
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// EOF, possibly after a torn final line from an interrupted
+			// write; that page is simply fetched again.
+			break
+		}
+		var page journalPage
+		if err := json.Unmarshal(line, &page); err != nil {
+			break
+		}
+		valid += int64(len(line))
+		state.Messages = append(state.Messages, page.Messages...)
+		state.Next = page.Next
+		state.Done = page.Done
+		state.Resumed = true
+	}
+	return state, valid, nil
+}
+
+// Append records a fetched page and the link to the page after it. An empty
+// next marks the export as complete.
+func (j *Journal) Append(msgs []graph.Message, next string) error {
+	return j.write(journalPage{Messages: msgs, Next: next, Done: next == ""})
+}
+
+func (j *Journal) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing export journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// Remove closes and deletes the journal once the export has been written.
+func (j *Journal) Remove() error {
+	j.f.Close()
+	return os.Remove(j.f.Name())
+}
@@ -0,0 +1,212 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxBatchSize is the most sub-requests Graph accepts in one $batch call.
+// Batch splits longer lists into several calls.
+const MaxBatchSize = 20
+
+// BatchRequest is one request sent through Batch.
+type BatchRequest struct {
+	Method string
+	// Path is relative to the base URL, as for single requests, e.g.
+	// "/me/chats/{id}?$expand=members".
+	Path string
+	// Body, when not nil, is sent as JSON.
+	Body any
+	// Idempotent marks a POST that is safe to repeat after a server error,
+	// such as setting a reaction. Other methods are judged by idempotentMethod.
+	Idempotent bool
+}
+
+// BatchResponse is the outcome of one BatchRequest.
+type BatchResponse struct {
+	Status int
+	Header http.Header
+	Body   json.RawMessage
+	// Err is set when the request failed: an *APIError, wrapped in a
+	// TransientError when Graph was still throttling or unavailable after
+	// the retries allowed by the client's RetryPolicy.
+	Err error
+}
+
+// Decode returns r.Err if the request failed and otherwise unmarshals the
+// response body into v.
+func (r BatchResponse) Decode(v any) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("parsing batch response: %w", err)
+	}
+	return nil
+}
+
+type batchItem struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"`
+}
+
+type batchItemResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Batch sends reqs through Graph's JSON batching endpoint, MaxBatchSize at
+// a time, and returns one response per request in the same order. Graph runs
+// the requests of a batch in any order.
+//
+// Requests that are throttled (429) or refused as unavailable (503) are
+// retried in a later batch, as are other server errors for idempotent
+// requests, waiting as the client's RetryPolicy and the largest Retry-After
+// ask. Other failures are reported per request in BatchResponse.Err. Each
+// sub-request counts against the client's RateLimit, and throttled ones slow
+// it down as a throttled call would.
+//
+// The returned error is set only when a batch call itself fails. The
+// requests it left unanswered then carry the same error.
+func (c *Client) Batch(ctx context.Context, reqs []BatchRequest) ([]BatchResponse, error) {
+	results := make([]BatchResponse, len(reqs))
+	pending := make([]int, len(reqs))
+	for i := range pending {
+		pending[i] = i
+	}
+	attempts := max(c.Retry.MaxAttempts, 1)
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		for start := 0; start < len(pending); start += MaxBatchSize {
+			chunk := pending[start:min(start+MaxBatchSize, len(pending))]
+			if err := c.sendBatch(ctx, reqs, chunk, results); err != nil {
+				for _, i := range pending[start:] {
+					results[i] = BatchResponse{Err: err}
+				}
+				return results, err
+			}
+		}
+
+		var retry []int
+		var wait time.Duration
+		waitOK := true
+		for _, i := range pending {
+			r := results[i]
+			if r.Err == nil || !retryable(r.Status, reqs[i].idempotent()) {
+				continue
+			}
+			retry = append(retry, i)
+			d, ok := c.Retry.delay(&http.Response{Header: r.Header}, attempt)
+			wait = max(wait, d)
+			waitOK = waitOK && ok
+		}
+		if len(retry) == 0 || attempt+1 >= attempts {
+			break
+		}
+		if !waitOK {
+			c.log.Warn("not retrying batch items: server asked to wait too long", "items", len(retry), "retry_after", wait)
+			break
+		}
+		c.log.Warn("batch items throttled or failed, waiting", "items", len(retry), "attempt", attempt+1, "wait", wait)
+		if err := sleep(ctx, wait); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return results, nil
+			}
+			return results, err
+		}
+		pending = retry
+	}
+	return results, nil
+}
+
+func (r BatchRequest) idempotent() bool {
+	return r.Idempotent || idempotentMethod(r.Method)
+}
+
+// sendBatch sends the requests at indexes idx as one $batch call and stores
+// their responses in results.
+func (c *Client) sendBatch(ctx context.Context, reqs []BatchRequest, idx []int, results []BatchResponse) error {
+	items := make([]batchItem, len(idx))
+	for n, i := range idx {
+		r := reqs[i]
+		items[n] = batchItem{ID: strconv.Itoa(i), Method: r.Method, URL: r.Path, Body: r.Body}
+		if r.Body != nil {
+			items[n].Headers = map[string]string{"Content-Type": "application/json"}
+		}
+	}
+	data, err := json.Marshal(map[string]any{"requests": items})
+	if err != nil {
+		return fmt.Errorf("marshalling batch: %w", err)
+	}
+
+	// Graph throttles each sub-request on its own; the call itself takes
+	// the first token.
+	if err := c.limit.charge(ctx, len(items)-1); err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, "POST", "/$batch", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading batch response: %w", err)
+	}
+	var result struct {
+		Responses []batchItemResponse `json:"responses"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("parsing batch response: %w", err)
+	}
+	c.log.Debug("sent batch", "items", len(idx), "responses", len(result.Responses))
+
+	answered := map[int]bool{}
+	throttled := false
+	for _, item := range result.Responses {
+		i, err := strconv.Atoi(item.ID)
+		if err != nil || i < 0 || i >= len(results) {
+			continue
+		}
+		answered[i] = true
+		header := http.Header{}
+		for k, v := range item.Headers {
+			header.Set(k, v)
+		}
+		r := BatchResponse{Status: item.Status, Header: header, Body: item.Body}
+		switch {
+		case item.Status == http.StatusTooManyRequests:
+			throttled = true
+			r.Err = TransientError{newAPIError(item.Status, header, item.Body)}
+		case item.Status >= 500:
+			r.Err = TransientError{newAPIError(item.Status, header, item.Body)}
+		case item.Status >= 400:
+			r.Err = newAPIError(item.Status, header, item.Body)
+		}
+		results[i] = r
+	}
+	if throttled {
+		if rate := c.limit.throttled(); rate > 0 {
+			c.log.Info("slowing down after throttling", "rate_limit", rate)
+		}
+	}
+	for _, i := range idx {
+		if !answered[i] {
+			results[i] = BatchResponse{Err: fmt.Errorf("no response to %s %s in batch", reqs[i].Method, reqs[i].Path)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/piotrwolkowski/tcli/graphtest"
+)
+
+func TestBatchSplitsAndReportsPerItem(t *testing.T) {
+	c, srv := fakeClient(t)
+	// 46 sub-requests would take seconds at the default rate.
+	c.limit = newLimiter(RateLimit{})
+	var ids []string
+	for i := range 45 {
+		id := fmt.Sprintf("chat-%d", i)
+		srv.AddChat(graphtest.Chat{ID: id, Topic: "Topic " + id})
+		ids = append(ids, id)
+	}
+	ids = append(ids, "missing")
+
+	results, err := c.GetChats(context.Background(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(ids) {
+		t.Fatalf("GetChats() returned %d results, want %d", len(results), len(ids))
+	}
+	for i, r := range results[:45] {
+		if r.Err != nil || r.Chat == nil || r.Chat.ID != ids[i] {
+			t.Errorf("result %d = %+v, want chat %s", i, r, ids[i])
+		}
+	}
+	if last := results[45]; !IsStatus(last.Err, http.StatusNotFound) {
+		t.Errorf("missing chat error = %v, want 404", last.Err)
+	}
+
+	batches := 0
+	for _, r := range srv.Requests() {
+		if r.Path == "/v1.0/$batch" {
+			batches++
+		}
+	}
+	if batches != 3 {
+		t.Errorf("made %d batch calls for 46 requests, want 3", batches)
+	}
+}
+
+func TestBatchRetriesThrottledItems(t *testing.T) {
+	c, srv := fakeClient(t)
+	for _, id := range []string{"a", "b", "c"} {
+		srv.AddChat(graphtest.Chat{ID: id})
+	}
+	srv.Fail(graphtest.Fault{Path: "/me/chats/b", Status: http.StatusTooManyRequests, Code: "TooManyRequests", RetryAfter: "0"})
+
+	results, err := c.PostMessages(context.Background(), []string{"a", "b", "c"}, SendMessageRequest{Body: MessageBody{Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Err != nil || r.Message == nil || r.Message.ID == "" {
+			t.Errorf("send to %s = %+v, want success", r.ChatID, r)
+		}
+		if n := len(srv.Messages(r.ChatID)); n != 1 {
+			t.Errorf("chat %s has %d messages, want exactly 1", r.ChatID, n)
+		}
+	}
+}
+
+func TestBatchDoesNotRetryFailedSends(t *testing.T) {
+	c, srv := fakeClient(t)
+	srv.AddChat(graphtest.Chat{ID: "a"})
+	srv.Fail(graphtest.Fault{Path: "/me/chats/a", Status: http.StatusBadGateway, Times: 5})
+
+	results, err := c.PostMessages(context.Background(), []string{"a"}, SendMessageRequest{Body: MessageBody{Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsTransient(results[0].Err) || !IsStatus(results[0].Err, http.StatusBadGateway) {
+		t.Errorf("send error = %v, want transient 502", results[0].Err)
+	}
+	sends := 0
+	for _, r := range srv.Requests() {
+		if r.Method == http.MethodPost && r.Path == "/v1.0/me/chats/a/messages" {
+			sends++
+		}
+	}
+	if sends != 1 {
+		t.Errorf("send was attempted %d times, want 1", sends)
+	}
+}
+
+func TestBatchGivesUpAfterMaxAttempts(t *testing.T) {
+	c, srv := fakeClient(t)
+	srv.AddChat(graphtest.Chat{ID: "a"})
+	srv.Fail(graphtest.Fault{Path: "/me/chats/a", Status: http.StatusTooManyRequests, RetryAfter: "0", Times: 10})
+
+	results, err := c.GetChats(context.Background(), []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsTransient(results[0].Err) || !IsStatus(results[0].Err, http.StatusTooManyRequests) {
+		t.Errorf("GetChats() error = %v, want transient 429", results[0].Err)
+	}
+}
+
+func TestBatchFeedsRateLimit(t *testing.T) {
+	c, srv := fakeClient(t)
+	c.limit = newLimiter(RateLimit{Rate: 100, Burst: MaxBatchSize})
+	var ids []string
+	for i := range MaxBatchSize {
+		id := fmt.Sprintf("chat-%d", i)
+		srv.AddChat(graphtest.Chat{ID: id})
+		ids = append(ids, id)
+	}
+	ctx := context.Background()
+
+	if _, err := c.GetChats(ctx, ids); err != nil {
+		t.Fatal(err)
+	}
+	// A full batch takes one token per sub-request, leaving the bucket
+	// about empty rather than nearly full.
+	if c.limit.tokens > 2 {
+		t.Errorf("%v tokens left after a batch of %d, want about none", c.limit.tokens, MaxBatchSize)
+	}
+
+	srv.Fail(graphtest.Fault{Path: "/me/chats/chat-3", Status: http.StatusTooManyRequests, Code: "TooManyRequests", RetryAfter: "0"})
+	if _, err := c.GetChats(ctx, ids[3:4]); err != nil {
+		t.Fatal(err)
+	}
+	if c.limit.rate >= 100 {
+		t.Errorf("rate after a throttled batch item = %v, want it lowered", c.limit.rate)
+	}
+}
@@ -5,14 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
+	"net/url"
 	"strings"
 )
 
 type Chat struct {
-	ID       string       `json:"id"`
-	Topic    string       `json:"topic"`
-	ChatType string       `json:"chatType"`
-	Members  []ChatMember `json:"members"`
+	ID          string       `json:"id"`
+	Topic       string       `json:"topic"`
+	ChatType    string       `json:"chatType"`
+	LastUpdated string       `json:"lastUpdatedDateTime"`
+	Members     []ChatMember `json:"members"`
 }
 
 type ChatMember struct {
@@ -20,42 +23,79 @@ type ChatMember struct {
 	Email       string `json:"email"`
 }
 
-type chatsResponse struct {
-	Value    []Chat `json:"value"`
-	NextLink string `json:"@odata.nextLink"`
+// Chats streams the signed-in user's chats with their members.
+func (c *Client) Chats(ctx context.Context, opts PageOptions) iter.Seq2[Chat, error] {
+	if c.appOnly {
+		return func(yield func(Chat, error) bool) {
+			yield(Chat{}, userRequired("listing your chats"))
+		}
+	}
+	return Paginate[Chat](ctx, c, "/me/chats?$expand=members", opts)
+}
+
+// UserChats streams the chats of the user with the given ID or user
+// principal name, which also works with app-only authentication.
+func (c *Client) UserChats(ctx context.Context, userID string, opts PageOptions) iter.Seq2[Chat, error] {
+	return Paginate[Chat](ctx, c, "/users/"+url.PathEscape(userID)+"/chats?$expand=members", opts)
 }
 
 func (c *Client) ListChats(ctx context.Context) ([]Chat, error) {
 	var allChats []Chat
-	path := "/me/chats?$expand=members&$top=50"
-
-	for path != "" {
-		resp, err := c.do(ctx, "GET", path, nil)
+	for chat, err := range c.Chats(ctx, PageOptions{Top: 50}) {
 		if err != nil {
 			return nil, err
 		}
+		allChats = append(allChats, chat)
+	}
+	return allChats, nil
+}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("reading response: %w", err)
-		}
+// GetChat returns a single chat with its members.
+func (c *Client) GetChat(ctx context.Context, chatID string) (*Chat, error) {
+	resp, err := c.do(ctx, "GET", c.chatPath(chatID)+"?$expand=members", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		var result chatsResponse
-		if err := json.Unmarshal(body, &result); err != nil {
-			return nil, fmt.Errorf("parsing chats response: %w", err)
-		}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	var chat Chat
+	if err := json.Unmarshal(body, &chat); err != nil {
+		return nil, fmt.Errorf("parsing chat response: %w", err)
+	}
+	return &chat, nil
+}
 
-		allChats = append(allChats, result.Value...)
+// ChatResult is the outcome of fetching one chat with GetChats.
+type ChatResult struct {
+	ChatID string
+	Chat   *Chat
+	Err    error
+}
 
-		if result.NextLink != "" {
-			path = strings.TrimPrefix(result.NextLink, baseURL)
-		} else {
-			path = ""
+// GetChats fetches several chats with their members in as few requests as
+// batching allows. Results are in the order of chatIDs; a chat that could not
+// be fetched has Err set. As with Batch, a non-nil error means a batch call
+// failed and the results after it are incomplete.
+func (c *Client) GetChats(ctx context.Context, chatIDs []string) ([]ChatResult, error) {
+	reqs := make([]BatchRequest, len(chatIDs))
+	for i, id := range chatIDs {
+		reqs[i] = BatchRequest{Method: "GET", Path: c.chatPath(id) + "?$expand=members"}
+	}
+	resps, err := c.Batch(ctx, reqs)
+	results := make([]ChatResult, len(chatIDs))
+	for i, r := range resps {
+		results[i].ChatID = chatIDs[i]
+		var chat Chat
+		if results[i].Err = r.Decode(&chat); results[i].Err == nil {
+			results[i].Chat = &chat
 		}
 	}
-
-	return allChats, nil
+	return results, err
 }
 
 func ChatDisplayName(chat Chat) string {
@@ -3,38 +3,193 @@ package graph
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"strconv"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/piotrwolkowski/tcli/config"
 	"github.com/piotrwolkowski/tcli/internal/auth"
 )
 
-const (
-	baseURL    = "https://graph.microsoft.com/v1.0"
-	maxRetries = 3
-)
+// DefaultBaseURL is the Graph endpoint used unless WithBaseURL is given.
+const DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+
+// DefaultUserAgent identifies tcli to Graph unless WithUserAgent is given.
+const DefaultUserAgent = "tcli"
+
+// TokenSource supplies the access token sent with each request.
+// auth.UserTokenSource satisfies it.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) { return f(ctx) }
+
+// PermissionRequester is implemented by token sources that can add a Graph
+// permission, e.g. "Files.ReadWrite", to their tokens when a call first needs
+// it. auth.UserTokenSource satisfies it; other sources are assumed to hold
+// every permission they need.
+type PermissionRequester interface {
+	RequestPermission(ctx context.Context, permission string) error
+}
 
 type Client struct {
-	http *http.Client
+	http      *http.Client
+	baseURL   string
+	userAgent string
+	tokens    TokenSource
+	log       *slog.Logger
+	limit     *limiter
+	appOnly   bool
+	// Retry controls how failed requests are retried; the zero value makes a
+	// single attempt.
+	Retry RetryPolicy
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sends requests to baseURL instead of DefaultBaseURL, e.g. a
+// local fake server or a national cloud endpoint.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimRight(baseURL, "/") }
+}
+
+// WithHTTPClient sends requests through hc, e.g. one with a custom transport.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithTokenSource authenticates requests with tokens from ts instead of the
+// signed-in user's cached token.
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client) { c.tokens = ts }
 }
 
-func NewClient() *Client {
-	return &Client{http: &http.Client{}}
+// WithUserAgent sets the User-Agent header sent with each request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
 }
 
-type graphErrorBody struct {
-	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+// WithLogger logs requests, retries, throttling and paging to l.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) { c.log = l }
 }
 
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) { c.Retry = p }
+}
+
+// WithAppOnly marks the token source as app-only. Chats are then addressed
+// as /chats/{id} rather than under /me, and calls that need a signed-in user
+// fail with ErrUserRequired.
+func WithAppOnly() Option {
+	return func(c *Client) { c.appOnly = true }
+}
+
+// WithRateLimit replaces DefaultRateLimit. RateLimit{} turns client-side
+// limiting off.
+func WithRateLimit(l RateLimit) Option {
+	return func(c *Client) { c.limit = newLimiter(l) }
+}
+
+// requirePermission asks the token source for permission if it supports it.
+func (c *Client) requirePermission(ctx context.Context, permission string) error {
+	if pr, ok := c.tokens.(PermissionRequester); ok {
+		return pr.RequestPermission(ctx, permission)
+	}
+	return nil
+}
+
+// NewClient returns a client for the signed-in user, configured by opts.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		http:      &http.Client{},
+		baseURL:   DefaultBaseURL,
+		userAgent: DefaultUserAgent,
+		tokens:    auth.UserTokenSource{},
+		log:       slog.New(slog.DiscardHandler),
+		limit:     newLimiter(DefaultRateLimit),
+		Retry:     DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// chatPath returns the path of a chat: under /me for a signed-in user, or
+// under /chats for an app, which has no "me".
+func (c *Client) chatPath(chatID string) string {
+	if c.appOnly {
+		return "/chats/" + url.PathEscape(chatID)
+	}
+	return "/me/chats/" + url.PathEscape(chatID)
+}
+
+// relative turns a nextLink returned by Graph into a path for do. Links to
+// another host are kept absolute.
+func (c *Client) relative(link string) string {
+	return strings.TrimPrefix(link, c.baseURL)
+}
+
+// requestURL resolves a path passed to do against the base URL. Absolute
+// URLs, such as next links on another host, are only used when trusted,
+// since the access token is sent with them.
+func (c *Client) requestURL(path string) (string, error) {
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		return c.baseURL + path, nil
+	}
+	if !c.trusted(path) {
+		u, _ := url.Parse(path)
+		host := path
+		if u != nil {
+			host = u.Scheme + "://" + u.Host
+		}
+		return "", fmt.Errorf("refusing to send the access token to %s: not a Microsoft Graph endpoint", host)
+	}
+	return path, nil
+}
+
+// trusted reports whether an absolute URL is on the configured base URL's
+// scheme and host, or on the Graph host of any cloud over HTTPS.
+func (c *Client) trusted(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	if base, err := url.Parse(c.baseURL); err == nil && u.Scheme == base.Scheme && u.Host == base.Host {
+		return true
+	}
+	return u.Scheme == "https" && slices.Contains(config.GraphHosts(), u.Host)
+}
+
+// do sends a request, retrying according to c.Retry. POST requests are only
+// retried when Graph cannot have acted on them; use doIdempotent for POSTs
+// that are safe to repeat.
 func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
-	token, err := auth.GetToken(ctx)
+	return c.send(ctx, method, path, "application/json", body, idempotentMethod(method))
+}
+
+// doIdempotent is do for requests that are safe to repeat whatever their
+// method, such as searches and setting a reaction.
+func (c *Client) doIdempotent(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	return c.send(ctx, method, path, "application/json", body, true)
+}
+
+func (c *Client) send(ctx context.Context, method, path, contentType string, body io.Reader, idempotent bool) (*http.Response, error) {
+	token, err := c.tokens.Token(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -48,9 +203,13 @@ func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*
 		}
 	}
 
-	reqURL := baseURL + path
+	reqURL, err := c.requestURL(path)
+	if err != nil {
+		return nil, err
+	}
+	attempts := max(c.Retry.MaxAttempts, 1)
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
+	for attempt := 0; ; attempt++ {
 		var bodyReader io.Reader
 		if bodyBytes != nil {
 			bodyReader = bytes.NewReader(bodyBytes)
@@ -61,63 +220,91 @@ func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*
 			return nil, fmt.Errorf("creating request: %w", err)
 		}
 		req.Header.Set("Authorization", "Bearer "+token)
-		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Type", contentType)
+		if c.userAgent != "" {
+			req.Header.Set("User-Agent", c.userAgent)
+		}
+		// Microsoft support can trace a request by this ID.
+		req.Header.Set("client-request-id", newRequestID())
 
-		resp, err := c.http.Do(req)
+		release, err := c.limit.acquire(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("request failed: %w", err)
+			return nil, err
 		}
 
-		if resp.StatusCode == 429 {
-			resp.Body.Close()
-			if attempt == maxRetries {
-				return nil, fmt.Errorf("rate limited by Graph API — try again later")
-			}
-			wait := retryAfter(resp, attempt)
-			select {
-			case <-ctx.Done():
+		var failure error
+		start := time.Now()
+		resp, err := c.http.Do(req)
+		if err != nil {
+			release()
+		} else {
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+		}
+		if err == nil {
+			c.log.LogAttrs(ctx, slog.LevelDebug, "graph request",
+				slog.String("method", method), slog.String("path", path),
+				slog.Int("status", resp.StatusCode), slog.Duration("duration", time.Since(start)),
+				slog.String("request_id", resp.Header.Get("request-id")))
+		}
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
 				return nil, ctx.Err()
-			case <-time.After(wait):
 			}
-			continue
-		}
-
-		if resp.StatusCode >= 400 {
+			failure = TransientError{fmt.Errorf("request failed: %w", err)}
+			if !retryableNetErr(err, idempotent) {
+				return nil, failure
+			}
+		case resp.StatusCode == http.StatusTooManyRequests:
+			failure = TransientError{parseGraphError(resp)}
+			if rate := c.limit.throttled(); rate > 0 {
+				c.log.Info("slowing down after throttling", "rate_limit", rate)
+			}
+		case resp.StatusCode >= 500:
+			failure = TransientError{parseGraphError(resp)}
+			if !retryable(resp.StatusCode, idempotent) {
+				resp.Body.Close()
+				return nil, failure
+			}
+		case resp.StatusCode >= 400:
 			defer resp.Body.Close()
 			return nil, parseGraphError(resp)
+		default:
+			c.limit.succeeded()
+			return resp, nil
 		}
-
-		return resp, nil
-	}
-
-	return nil, fmt.Errorf("request failed after %d retries", maxRetries)
-}
-
-// retryAfter returns how long to wait before the next retry, using the
-// Retry-After response header when present and falling back to exponential backoff.
-func retryAfter(resp *http.Response, attempt int) time.Duration {
-	if h := resp.Header.Get("Retry-After"); h != "" {
-		if secs, err := strconv.Atoi(h); err == nil {
-			return time.Duration(secs) * time.Second
+		if resp != nil {
+			resp.Body.Close()
 		}
-	}
-	// 2s, 4s, 8s
-	return time.Duration(2<<attempt) * time.Second
-}
 
-func parseGraphError(resp *http.Response) error {
-	body, _ := io.ReadAll(resp.Body)
-
-	var ge graphErrorBody
-	if err := json.Unmarshal(body, &ge); err == nil && ge.Error.Code != "" {
-		switch resp.StatusCode {
-		case 401:
-			return fmt.Errorf("unauthorized — session may have expired, run: tcli login")
-		case 403:
-			return fmt.Errorf("permission denied — ensure Chat.Read and ChatMessage.Send are granted in your Azure app registration")
+		if attempt+1 >= attempts {
+			return nil, failure
+		}
+		wait, ok := c.Retry.delay(resp, attempt)
+		if !ok {
+			c.log.Warn("not retrying: server asked to wait too long", "method", method, "path", path, "retry_after", wait)
+			return nil, failure
+		}
+		level := slog.LevelInfo
+		msg := "retrying request"
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			level, msg = slog.LevelWarn, "throttled by Graph, waiting"
+		}
+		c.log.Log(ctx, level, msg, "method", method, "path", path, "attempt", attempt+1, "wait", wait, "error", failure)
+		if err := sleep(ctx, wait); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%w (retry abandoned: deadline reached)", failure)
+			}
+			return nil, err
 		}
-		return fmt.Errorf("Graph API error (%s): %s", ge.Error.Code, ge.Error.Message)
 	}
+}
 
-	return fmt.Errorf("Graph API error %d: %s", resp.StatusCode, string(body))
+// newRequestID returns a random UUID for the client-request-id header.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
 }
@@ -1,11 +1,17 @@
 package graph
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/auth"
 )
 
 func makeResp(statusCode int, body string) *http.Response {
@@ -68,6 +74,25 @@ func TestParseGraphError(t *testing.T) {
 	}
 }
 
+func TestAPIError(t *testing.T) {
+	resp := makeResp(404, `{"error":{"code":"NotFound","message":"No chat.","innerError":{"request-id":"r-1","client-request-id":"c-1"}}}`)
+	err := fmt.Errorf("sending message: %w", parseGraphError(resp))
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("errors.As(%v) found no *APIError", err)
+	}
+	if apiErr.StatusCode != 404 || apiErr.Code != "NotFound" || apiErr.RequestID != "r-1" || apiErr.ClientRequestID != "c-1" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+	if !IsStatus(err, 404) || IsStatus(err, 403) {
+		t.Error("IsStatus does not match the wrapped status code")
+	}
+	if IsStatus(errors.New("plain"), 404) {
+		t.Error("IsStatus(plain error) = true")
+	}
+}
+
 func TestRetryAfter(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -107,12 +132,69 @@ func TestRetryAfter(t *testing.T) {
 		},
 	}
 
+	// The default backoff without jitter, so the waits are exact.
+	p := DefaultRetryPolicy
+	p.Jitter, p.MaxDelay = 0, 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := p.delay(tt.resp, tt.attempt)
+			if got != tt.want || !ok {
+				t.Errorf("delay() = %v, %v; want %v, true", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "plain error", err: errors.New("boom"), want: false},
+		{name: "transient", err: TransientError{errors.New("connection reset")}, want: true},
+		{name: "wrapped transient", err: fmt.Errorf("sending: %w", TransientError{errors.New("503")}), want: true},
+		{name: "sign-in unavailable", err: fmt.Errorf("refreshing access token: %w", auth.ErrUnavailable), want: true},
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := retryAfter(tt.resp, tt.attempt)
-			if got != tt.want {
-				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestClientOptions(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer fake-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("User-Agent"); got != "tcli-test/1.0" {
+			t.Errorf("User-Agent = %q", got)
+		}
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"value":[{"id":"c2"}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"value":[{"id":"c1"}],"@odata.nextLink":"%s/v1.0/me/chats?page=2"}`, srv.URL)
+	}))
+	defer srv.Close()
+
+	c := NewClient(
+		WithBaseURL(srv.URL+"/v1.0/"),
+		WithHTTPClient(srv.Client()),
+		WithTokenSource(TokenSourceFunc(func(context.Context) (string, error) { return "fake-token", nil })),
+		WithUserAgent("tcli-test/1.0"),
+	)
+	chats, err := c.ListChats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chats) != 2 || chats[0].ID != "c1" || chats[1].ID != "c2" {
+		t.Errorf("ListChats() = %+v, want c1 then c2", chats)
+	}
+}
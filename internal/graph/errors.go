@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/piotrwolkowski/tcli/internal/auth"
+)
+
+// APIError is an error response from Graph. Use errors.As to inspect it:
+//
+//	var apiErr *graph.APIError
+//	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound { ... }
+type APIError struct {
+	StatusCode int
+	// Code and Message come from the Graph error body, e.g. "NotFound".
+	// Both are empty when the body was not a Graph error.
+	Code    string
+	Message string
+	// RequestID and ClientRequestID identify the request to Microsoft support.
+	RequestID       string
+	ClientRequestID string
+	// Body is the raw response body when it was not a Graph error.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return "unauthorized — session may have expired, run: tcli login"
+	case e.StatusCode == http.StatusForbidden:
+		return "permission denied — ensure Chat.Read and ChatMessage.Send are granted in your Azure app registration, and Chat.ReadWrite or Files.ReadWrite to edit messages or attach files"
+	case e.StatusCode == http.StatusTooManyRequests:
+		return "rate limited by Graph API — try again later"
+	case e.Code != "":
+		return fmt.Sprintf("Graph API error (%s): %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("Graph API error %d: %s", e.StatusCode, e.Body)
+}
+
+// IsStatus reports whether err is an APIError with the given HTTP status.
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// ErrUserRequired is returned, before any request is sent, by calls that act
+// as the signed-in user when the client uses app-only authentication.
+var ErrUserRequired = errors.New("not available with app-only authentication, which has no signed-in user — unset the app credentials and run: tcli login")
+
+func userRequired(op string) error {
+	return fmt.Errorf("%s: %w", op, ErrUserRequired)
+}
+
+// TransientError marks failures that may succeed if retried later: network
+// errors, throttling and server-side (5xx) errors.
+type TransientError struct {
+	Err error
+}
+
+func (e TransientError) Error() string { return e.Err.Error() }
+func (e TransientError) Unwrap() error { return e.Err }
+
+// IsTransient reports whether err is a failure worth retrying later, such as
+// a network error, throttling or a 5xx response from Graph, or the sign-in
+// service being unavailable while a token is refreshed.
+func IsTransient(err error) bool {
+	var t TransientError
+	return errors.As(err, &t) || errors.Is(err, auth.ErrUnavailable)
+}
+
+type graphErrorBody struct {
+	Error struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError struct {
+			RequestID       string `json:"request-id"`
+			ClientRequestID string `json:"client-request-id"`
+		} `json:"innerError"`
+	} `json:"error"`
+}
+
+func parseGraphError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	return newAPIError(resp.StatusCode, resp.Header, body)
+}
+
+// newAPIError builds the error for a failed response, whether it came over
+// HTTP or as one item of a batch.
+func newAPIError(status int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode:      status,
+		RequestID:       header.Get("request-id"),
+		ClientRequestID: header.Get("client-request-id"),
+	}
+	var ge graphErrorBody
+	if err := json.Unmarshal(body, &ge); err == nil && ge.Error.Code != "" {
+		apiErr.Code = ge.Error.Code
+		apiErr.Message = ge.Error.Message
+		// Some gateways drop the headers; the body carries the same IDs.
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = ge.Error.InnerError.RequestID
+		}
+		if apiErr.ClientRequestID == "" {
+			apiErr.ClientRequestID = ge.Error.InnerError.ClientRequestID
+		}
+	} else {
+		apiErr.Body = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// MaxUploadSize is the largest file UploadChatFile accepts. Larger files
+// need an upload session, which tcli does not implement.
+const MaxUploadSize = 4 << 20
+
+// chatFilesFolder is the OneDrive folder Teams keeps files shared in chats in.
+const chatFilesFolder = "Microsoft Teams Chat Files"
+
+// DriveItem is a file in OneDrive.
+type DriveItem struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	WebURL string `json:"webUrl"`
+	// ETag has the form "{GUID},version"; the GUID identifies the file in
+	// message attachments.
+	ETag string `json:"eTag"`
+}
+
+// attachmentID returns the GUID Teams uses to reference the file.
+func (d DriveItem) attachmentID() string {
+	id, _, _ := strings.Cut(strings.Trim(d.ETag, `"`), ",")
+	return strings.Trim(id, "{}")
+}
+
+// UploadChatFile stores content in the signed-in user's Teams chat files
+// folder, as Teams does for files shared in chats. An existing file with the
+// same name is kept and the upload renamed. The Files.ReadWrite permission
+// is requested on first use.
+func (c *Client) UploadChatFile(ctx context.Context, name string, content []byte) (*DriveItem, error) {
+	if c.appOnly {
+		return nil, userRequired("uploading files")
+	}
+	if len(content) > MaxUploadSize {
+		return nil, fmt.Errorf("%s is %d bytes — files over %d MB cannot be attached", name, len(content), MaxUploadSize>>20)
+	}
+	if err := c.requirePermission(ctx, "Files.ReadWrite"); err != nil {
+		return nil, err
+	}
+
+	path := "/me/drive/root:/" + url.PathEscape(chatFilesFolder) + "/" + url.PathEscape(name) +
+		":/content?@microsoft.graph.conflictBehavior=rename"
+	resp, err := c.send(ctx, "PUT", path, "application/octet-stream", bytes.NewReader(content), true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	var item DriveItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &item, nil
+}
+
+// SendFile uploads content with UploadChatFile and posts it to the chat as a
+// file attachment, with text as the message above it. Chat members get
+// access to the file through the message.
+func (c *Client) SendFile(ctx context.Context, chatID, name string, content []byte, text string) (*SendMessageResponse, error) {
+	item, err := c.UploadChatFile(ctx, name, content)
+	if err != nil {
+		return nil, fmt.Errorf("uploading %s: %w", name, err)
+	}
+	id := item.attachmentID()
+	return c.PostMessage(ctx, chatID, SendMessageRequest{
+		Body: MessageBody{
+			ContentType: "html",
+			Content:     html.EscapeString(text) + `<attachment id="` + id + `"></attachment>`,
+		},
+		Attachments: []Attachment{{
+			ID:          id,
+			ContentType: "reference",
+			ContentURL:  item.WebURL,
+			Name:        item.Name,
+		}},
+	})
+}
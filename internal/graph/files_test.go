@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/piotrwolkowski/tcli/graphtest"
+)
+
+func TestSendFile(t *testing.T) {
+	c, srv := fakeClient(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := c.SendFile(ctx, "chat", "report.csv", []byte("a,b\n"), "Q3 <numbers>"); err != nil {
+			t.Fatalf("SendFile() = %v", err)
+		}
+	}
+
+	if data, ok := srv.File("Microsoft Teams Chat Files/report.csv"); !ok || string(data) != "a,b\n" {
+		t.Errorf("uploaded file = %q, %v", data, ok)
+	}
+	if _, ok := srv.File("Microsoft Teams Chat Files/report 1.csv"); !ok {
+		t.Error("second upload replaced the first instead of being renamed")
+	}
+	msgs := srv.Messages("chat")
+	if len(msgs) != 2 || len(msgs[0].Attachments) != 1 {
+		t.Fatalf("messages = %+v, want 2 with one attachment each", msgs)
+	}
+	a := msgs[0].Attachments[0]
+	if a.Name != "report.csv" || a.ContentURL == "" || !strings.Contains(msgs[0].Content, `<attachment id="`+a.ID+`">`) {
+		t.Errorf("attachment = %+v in %q", a, msgs[0].Content)
+	}
+	if !strings.HasPrefix(msgs[0].Content, "Q3 &lt;numbers&gt;") {
+		t.Errorf("message text not escaped: %q", msgs[0].Content)
+	}
+}
+
+func TestUploadChatFileTooLarge(t *testing.T) {
+	c, _ := fakeClient(t)
+	if _, err := c.UploadChatFile(context.Background(), "big.bin", make([]byte, MaxUploadSize+1)); err == nil {
+		t.Error("UploadChatFile() accepted a file over MaxUploadSize")
+	}
+}
+
+// permissionSource records the permissions requested of it.
+type permissionSource struct {
+	token     string
+	requested []string
+	err       error
+}
+
+func (p *permissionSource) Token(context.Context) (string, error) { return p.token, nil }
+
+func (p *permissionSource) RequestPermission(_ context.Context, permission string) error {
+	p.requested = append(p.requested, permission)
+	return p.err
+}
+
+func TestCallsRequestPermissions(t *testing.T) {
+	srv := graphtest.NewServer(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	srv.AddMessages("chat", graphtest.Message{ID: "m1", Content: "hi"})
+	ts := &permissionSource{token: srv.IssueToken()}
+	c := NewClient(WithBaseURL(srv.GraphURL()), WithTokenSource(ts))
+	ctx := context.Background()
+
+	if _, err := c.UploadChatFile(ctx, "a.txt", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateMessage(ctx, "chat", "m1", "edited"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Files.ReadWrite", "Chat.ReadWrite"}; !slices.Equal(ts.requested, want) {
+		t.Errorf("requested %q, want %q", ts.requested, want)
+	}
+
+	ts.err = errors.New("not granted")
+	if _, err := c.UploadChatFile(ctx, "b.txt", []byte("b")); !errors.Is(err, ts.err) {
+		t.Errorf("UploadChatFile() without the permission = %v", err)
+	}
+	if _, ok := srv.File("Microsoft Teams Chat Files/b.txt"); ok {
+		t.Error("file uploaded although the permission was refused")
+	}
+}
@@ -0,0 +1,122 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/piotrwolkowski/tcli/graphtest"
+)
+
+func fakeClient(t *testing.T) (*Client, *graphtest.Server) {
+	t.Helper()
+	srv := graphtest.NewServer(t)
+	token := srv.IssueToken()
+	c := NewClient(
+		WithBaseURL(srv.GraphURL()),
+		WithTokenSource(TokenSourceFunc(func(context.Context) (string, error) { return token, nil })),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	)
+	return c, srv
+}
+
+func TestListChatsPaginates(t *testing.T) {
+	c, srv := fakeClient(t)
+	srv.SetPageSize(2)
+	for i := range 5 {
+		srv.AddChat(graphtest.Chat{ID: fmt.Sprintf("chat-%d", i)})
+	}
+
+	chats, err := c.ListChats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chats) != 5 || chats[4].ID != "chat-4" {
+		t.Errorf("ListChats() returned %d chats, want all 5 in order", len(chats))
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("ListChats() made %d requests, want 3 pages", n)
+	}
+}
+
+func TestSendAndListMessages(t *testing.T) {
+	c, srv := fakeClient(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	srv.AddMessages("chat", graphtest.Message{From: "Alice", Content: "hi"})
+	ctx := context.Background()
+
+	resp, err := c.PostMessage(ctx, "chat", SendMessageRequest{Body: MessageBody{Content: "hello"}, Importance: ImportanceHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID == "" || resp.CreatedAt == "" {
+		t.Errorf("PostMessage() = %+v, want ID and creation time", resp)
+	}
+
+	msgs, err := c.ListMessages(ctx, "chat", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != resp.ID || msgs[0].Importance != ImportanceHigh || SenderName(msgs[1]) != "Alice" {
+		t.Errorf("ListMessages() = %+v, want the sent message then Alice's", msgs)
+	}
+}
+
+func TestFakeErrors(t *testing.T) {
+	c, srv := fakeClient(t)
+	srv.AddChat(graphtest.Chat{ID: "chat"})
+	ctx := context.Background()
+
+	srv.Throttle(2)
+	if _, err := c.ListChats(ctx); err != nil {
+		t.Errorf("ListChats() after two 429s = %v, want success on the third attempt", err)
+	}
+
+	if _, err := c.GetChat(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "NotFound") {
+		t.Errorf("GetChat(missing) = %v, want NotFound", err)
+	}
+
+	srv.Fail(graphtest.Fault{Method: "POST", Path: "/me/chats", Status: 500, Code: "InternalServerError"})
+	_, err := c.SendMessage(ctx, "chat", "hi")
+	if !IsTransient(err) {
+		t.Errorf("SendMessage() on 500 = %v, want a transient error", err)
+	}
+	if got := len(srv.Messages("chat")); got != 0 {
+		t.Errorf("chat has %d messages after a failed send, want 0", got)
+	}
+}
+
+func TestAppOnlyClient(t *testing.T) {
+	srv := graphtest.NewServer(t)
+	srv.AddChat(graphtest.Chat{ID: "chat", Topic: "Builds"})
+	srv.AddMessages("chat", graphtest.Message{From: "Alice", Content: "hi"})
+	token := srv.IssueAppToken()
+	c := NewClient(
+		WithBaseURL(srv.GraphURL()),
+		WithTokenSource(TokenSourceFunc(func(context.Context) (string, error) { return token, nil })),
+		WithAppOnly(),
+	)
+	ctx := context.Background()
+
+	chat, err := c.GetChat(ctx, "chat")
+	if err != nil || chat.Topic != "Builds" {
+		t.Errorf("GetChat() = %+v, %v", chat, err)
+	}
+	msgs, err := c.ListMessages(ctx, "chat", 0)
+	if err != nil || len(msgs) != 1 {
+		t.Errorf("ListMessages() = %d messages, %v", len(msgs), err)
+	}
+
+	n := len(srv.Requests())
+	if _, err := c.SendMessage(ctx, "chat", "hello"); !errors.Is(err, ErrUserRequired) {
+		t.Errorf("SendMessage() = %v, want ErrUserRequired", err)
+	}
+	if _, err := c.ListChats(ctx); !errors.Is(err, ErrUserRequired) {
+		t.Errorf("ListChats() = %v, want ErrUserRequired", err)
+	}
+	if len(srv.Requests()) != n {
+		t.Error("calls that need a user were sent to Graph")
+	}
+}
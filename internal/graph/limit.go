@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimit limits how fast and how many requests at once a Client sends,
+// so several tcli processes sharing an app registration stay below Graph's
+// throttling thresholds instead of relying on retries.
+type RateLimit struct {
+	// Rate is the sustained number of requests per second. Zero turns the
+	// rate limit off.
+	Rate float64
+	// Burst is how many requests may be sent back to back after a quiet
+	// spell; values below 1 mean 1.
+	Burst int
+	// MaxInFlight caps the number of concurrent requests. Zero means no cap.
+	MaxInFlight int
+}
+
+// DefaultRateLimit stays well inside Graph's per-app limits for chats.
+var DefaultRateLimit = RateLimit{Rate: 10, Burst: 10, MaxInFlight: 4}
+
+// Each 429 halves the current rate, down to the configured rate divided by
+// minRateDivisor; each successful request restores a recoverySteps-th of it.
+const (
+	minRateDivisor = 16
+	recoverySteps  = 20
+)
+
+// limiter is a token bucket whose rate adapts to throttling, plus a
+// semaphore on requests in flight.
+type limiter struct {
+	cfg      RateLimit
+	inFlight chan struct{}
+
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(cfg RateLimit) *limiter {
+	cfg.Burst = max(cfg.Burst, 1)
+	l := &limiter{cfg: cfg, rate: cfg.Rate, tokens: float64(cfg.Burst), last: time.Now()}
+	if cfg.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+// acquire waits until a request may be sent. The caller must call the
+// returned release function once the request is finished.
+func (l *limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release = func() {
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}
+	if err := sleep(ctx, l.reserve()); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// charge waits for n more tokens, for a request such as a $batch call that
+// Graph counts as several.
+func (l *limiter) charge(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	return sleep(ctx, l.reserveN(n))
+}
+
+// reserve takes a token and returns how long to wait until it is valid.
+// Taking tokens on credit keeps waiting requests in arrival order.
+func (l *limiter) reserve() time.Duration {
+	return l.reserveN(1)
+}
+
+// reserveN is reserve for n tokens.
+func (l *limiter) reserveN(n int) time.Duration {
+	if l.cfg.Rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, float64(l.cfg.Burst))
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// throttled slows the limiter down after a 429 and returns the new rate.
+func (l *limiter) throttled() float64 {
+	if l.cfg.Rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = max(l.rate/2, l.cfg.Rate/minRateDivisor)
+	l.tokens = min(l.tokens, 0)
+	return l.rate
+}
+
+// succeeded lets the rate recover towards the configured one.
+func (l *limiter) succeeded() {
+	if l.cfg.Rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = min(l.rate+l.cfg.Rate/recoverySteps, l.cfg.Rate)
+}
+
+// releaseBody calls release when the response body is closed, so a request
+// counts as in flight until its body has been read.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
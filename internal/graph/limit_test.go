@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterPacesRequests(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 100, Burst: 2})
+	for i, want := range []time.Duration{0, 0, 10 * time.Millisecond, 20 * time.Millisecond} {
+		got := l.reserve()
+		// Tokens refill between calls, so waits can only come out shorter.
+		if got > want || got < want-5*time.Millisecond {
+			t.Errorf("reservation %d waits %v, want about %v", i, got, want)
+		}
+	}
+}
+
+func TestLimiterAdaptsToThrottling(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 16, Burst: 1})
+	for _, want := range []float64{8, 4, 2, 1, 1} {
+		if got := l.throttled(); got != want {
+			t.Errorf("rate after 429 = %v, want %v", got, want)
+		}
+	}
+	if d := l.reserve(); d < 900*time.Millisecond {
+		t.Errorf("first request after throttling waits %v, want about 1s", d)
+	}
+	for range recoverySteps {
+		l.succeeded()
+	}
+	if l.rate != 16 {
+		t.Errorf("rate after recovery = %v, want the configured 16", l.rate)
+	}
+}
+
+func TestLimiterOff(t *testing.T) {
+	l := newLimiter(RateLimit{})
+	for range 100 {
+		if d := l.reserve(); d != 0 {
+			t.Fatalf("disabled limiter waits %v", d)
+		}
+	}
+	if l.throttled() != 0 {
+		t.Error("disabled limiter reported a rate")
+	}
+}
+
+// slowTransport answers after a delay, recording the peak concurrency.
+type slowTransport struct {
+	active, peak atomic.Int32
+}
+
+func (s *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		p := s.peak.Load()
+		if n <= p || s.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func TestClientMaxInFlight(t *testing.T) {
+	tr := &slowTransport{}
+	c := NewClient(
+		WithHTTPClient(&http.Client{Transport: tr}),
+		WithTokenSource(TokenSourceFunc(func(context.Context) (string, error) { return "token", nil })),
+		WithRateLimit(RateLimit{MaxInFlight: 2}),
+	)
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Go(func() {
+			resp, err := c.do(context.Background(), "GET", "/me", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		})
+	}
+	wg.Wait()
+	if p := tr.peak.Load(); p != 2 {
+		t.Errorf("peak concurrency = %d, want 2", p)
+	}
+}
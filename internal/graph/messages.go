@@ -6,14 +6,39 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Message importance levels. Urgent messages notify recipients repeatedly
+// until they are read.
+const (
+	ImportanceNormal = "normal"
+	ImportanceHigh   = "high"
+	ImportanceUrgent = "urgent"
+)
+
+// ValidateImportance reports whether importance is a level Graph accepts.
+// The empty string leaves the default (normal) in place.
+func ValidateImportance(importance string) error {
+	switch importance {
+	case "", ImportanceNormal, ImportanceHigh, ImportanceUrgent:
+		return nil
+	}
+	return fmt.Errorf("invalid importance %q — use normal, high or urgent", importance)
+}
+
 type SendMessageRequest struct {
-	Body MessageBody `json:"body"`
+	Body        MessageBody  `json:"body"`
+	Importance  string       `json:"importance,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
 }
 
 type MessageBody struct {
-	Content string `json:"content"`
+	ContentType string `json:"contentType,omitempty"`
+	Content     string `json:"content"`
 }
 
 type SendMessageResponse struct {
@@ -22,8 +47,19 @@ type SendMessageResponse struct {
 }
 
 func (c *Client) SendMessage(ctx context.Context, chatID, content string) (*SendMessageResponse, error) {
-	payload := SendMessageRequest{
+	return c.PostMessage(ctx, chatID, SendMessageRequest{
 		Body: MessageBody{Content: content},
+	})
+}
+
+// PostMessage sends a fully specified message, e.g. one with an importance.
+func (c *Client) PostMessage(ctx context.Context, chatID string, payload SendMessageRequest) (*SendMessageResponse, error) {
+	// Graph only lets apps post chat messages when importing history.
+	if c.appOnly {
+		return nil, userRequired("sending messages")
+	}
+	if err := ValidateImportance(payload.Importance); err != nil {
+		return nil, err
 	}
 
 	data, err := json.Marshal(payload)
@@ -50,3 +86,310 @@ func (c *Client) SendMessage(ctx context.Context, chatID, content string) (*Send
 
 	return &result, nil
 }
+
+// SendResult is the outcome of sending to one chat with PostMessages.
+type SendResult struct {
+	ChatID  string
+	Message *SendMessageResponse
+	Err     error
+}
+
+// PostMessages sends the same message to each of chatIDs, batching the
+// requests. Results are in the order of chatIDs; a send that failed has Err
+// set. Throttled sends are retried, but sends that failed with other server
+// errors are not, as they may have been posted. As with Batch, a non-nil
+// error means a batch call failed; the results show which sends were made.
+func (c *Client) PostMessages(ctx context.Context, chatIDs []string, payload SendMessageRequest) ([]SendResult, error) {
+	if c.appOnly {
+		return nil, userRequired("sending messages")
+	}
+	if err := ValidateImportance(payload.Importance); err != nil {
+		return nil, err
+	}
+	reqs := make([]BatchRequest, len(chatIDs))
+	for i, id := range chatIDs {
+		reqs[i] = BatchRequest{Method: "POST", Path: fmt.Sprintf("/me/chats/%s/messages", url.PathEscape(id)), Body: payload}
+	}
+	resps, err := c.Batch(ctx, reqs)
+	results := make([]SendResult, len(chatIDs))
+	for i, r := range resps {
+		results[i].ChatID = chatIDs[i]
+		var msg SendMessageResponse
+		if results[i].Err = r.Decode(&msg); results[i].Err == nil {
+			results[i].Message = &msg
+		}
+	}
+	return results, err
+}
+
+// Message is a chat message as returned by the Graph messages endpoints.
+type Message struct {
+	ID             string       `json:"id"`
+	MessageType    string       `json:"messageType"`
+	CreatedAt      string       `json:"createdDateTime"`
+	LastModifiedAt string       `json:"lastModifiedDateTime,omitempty"`
+	LastEditedAt   string       `json:"lastEditedDateTime,omitempty"`
+	DeletedAt      string       `json:"deletedDateTime,omitempty"`
+	ReplyToID      string       `json:"replyToId,omitempty"`
+	Importance     string       `json:"importance,omitempty"`
+	From           *MessageFrom `json:"from"`
+	Body           MessageBody  `json:"body"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+	Reactions      []Reaction   `json:"reactions,omitempty"`
+}
+
+// MessageFrom identifies the sender of a message. System messages have no
+// sender; bots and connectors appear as applications.
+type MessageFrom struct {
+	User        *Identity `json:"user"`
+	Application *Identity `json:"application"`
+}
+
+type Identity struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// Reaction is one user's reaction to a message. ReactionType is either an
+// emoji or one of the legacy names such as "like" or "heart".
+type Reaction struct {
+	ReactionType string       `json:"reactionType"`
+	DisplayName  string       `json:"displayName,omitempty"`
+	CreatedAt    string       `json:"createdDateTime"`
+	User         *MessageFrom `json:"user"`
+}
+
+// Attachment is a file, card or quoted message attached to a chat message.
+// Replies in chats are "messageReference" attachments whose Content is a JSON
+// document describing the quoted message.
+type Attachment struct {
+	ID          string `json:"id"`
+	ContentType string `json:"contentType"`
+	ContentURL  string `json:"contentUrl,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// MessageReference is the decoded Content of a "messageReference" attachment.
+type MessageReference struct {
+	MessageID      string       `json:"messageId"`
+	MessagePreview string       `json:"messagePreview"`
+	MessageSender  *MessageFrom `json:"messageSender"`
+}
+
+// Reference decodes a "messageReference" attachment. It returns false for any
+// other kind of attachment.
+func (a Attachment) Reference() (MessageReference, bool) {
+	var ref MessageReference
+	if a.ContentType != "messageReference" || a.Content == "" {
+		return ref, false
+	}
+	if err := json.Unmarshal([]byte(a.Content), &ref); err != nil {
+		return ref, false
+	}
+	return ref, true
+}
+
+type messagesResponse struct {
+	Value    []Message `json:"value"`
+	NextLink string    `json:"@odata.nextLink"`
+}
+
+// MessageQuery selects the first page of a message listing.
+type MessageQuery struct {
+	// Top is the page size; Graph caps it at 50.
+	Top int
+	// Before, when set, only returns messages created before this time.
+	Before time.Time
+	// After, when set, only returns messages created after this time.
+	After time.Time
+}
+
+// MessagesPage fetches one page of a chat's messages, newest first. Pass an
+// empty next to fetch the first page described by q, or the returned next
+// link to continue; an empty returned next link means there are no more pages.
+//
+// Graph only filters on createdDateTime with "lt", so the After bound is
+// applied here: paging stops at the first message older than it.
+func (c *Client) MessagesPage(ctx context.Context, chatID string, q MessageQuery, next string) ([]Message, string, error) {
+	path := next
+	if path == "" {
+		top := q.Top
+		if top <= 0 || top > 50 {
+			top = 50
+		}
+		params := url.Values{}
+		params.Set("$top", strconv.Itoa(top))
+		if !q.Before.IsZero() || !q.After.IsZero() {
+			params.Set("$orderby", "createdDateTime desc")
+		}
+		if !q.Before.IsZero() {
+			params.Set("$filter", "createdDateTime lt "+q.Before.UTC().Format(time.RFC3339))
+		}
+		path = c.chatPath(chatID) + "/messages?" + params.Encode()
+	} else {
+		path = c.relative(next)
+	}
+
+	resp, err := c.do(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading response: %w", err)
+	}
+
+	var result messagesResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, "", fmt.Errorf("parsing messages response: %w", err)
+	}
+
+	c.log.Debug("fetched page of messages", "chat_id", chatID, "items", len(result.Value), "more", result.NextLink != "")
+
+	if !q.After.IsZero() {
+		for i, m := range result.Value {
+			created, err := time.Parse(time.RFC3339, m.CreatedAt)
+			if err == nil && !created.After(q.After) {
+				return result.Value[:i], "", nil
+			}
+		}
+	}
+	return result.Value, result.NextLink, nil
+}
+
+// ListMessages returns up to limit messages from a chat, newest first. A limit
+// of zero or less fetches the whole history.
+func (c *Client) ListMessages(ctx context.Context, chatID string, limit int) ([]Message, error) {
+	var all []Message
+	q := MessageQuery{Top: limit}
+	next := ""
+	for {
+		msgs, nextLink, err := c.MessagesPage(ctx, chatID, q, next)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, msgs...)
+		if limit > 0 && len(all) >= limit {
+			return all[:limit], nil
+		}
+		if nextLink == "" {
+			return all, nil
+		}
+		next = nextLink
+	}
+}
+
+// SenderName returns the display name of whoever sent the message.
+func SenderName(m Message) string {
+	if m.From != nil {
+		if m.From.User != nil && m.From.User.DisplayName != "" {
+			return m.From.User.DisplayName
+		}
+		if m.From.Application != nil && m.From.Application.DisplayName != "" {
+			return m.From.Application.DisplayName
+		}
+	}
+	return "(system)"
+}
+
+// UpdateMessage replaces the content of a message the signed-in user sent.
+// The Chat.ReadWrite permission is requested on first use.
+func (c *Client) UpdateMessage(ctx context.Context, chatID, messageID, content string) error {
+	if c.appOnly {
+		return userRequired("editing messages")
+	}
+	if err := c.requirePermission(ctx, "Chat.ReadWrite"); err != nil {
+		return err
+	}
+	payload := SendMessageRequest{
+		Body: MessageBody{Content: content},
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshalling message: %w", err)
+	}
+
+	path := fmt.Sprintf("/chats/%s/messages/%s", url.PathEscape(chatID), url.PathEscape(messageID))
+	resp, err := c.do(ctx, "PATCH", path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// legacyReactions maps the reaction names used by older Teams clients to the
+// emoji they display as.
+var legacyReactions = map[string]string{
+	"like":      "👍",
+	"heart":     "❤️",
+	"laugh":     "😆",
+	"surprised": "😮",
+	"sad":       "😢",
+	"angry":     "😡",
+}
+
+// ReactionEmoji returns the emoji for a reaction type, translating legacy
+// reaction names.
+func ReactionEmoji(reactionType string) string {
+	if e, ok := legacyReactions[reactionType]; ok {
+		return e
+	}
+	return reactionType
+}
+
+// ReactionSummary renders a message's reactions as counts per emoji in order
+// of first use, e.g. "👍 2  ✅ 1". It is empty when there are no reactions.
+func ReactionSummary(m Message) string {
+	counts := map[string]int{}
+	var order []string
+	for _, r := range m.Reactions {
+		e := ReactionEmoji(r.ReactionType)
+		if counts[e] == 0 {
+			order = append(order, e)
+		}
+		counts[e]++
+	}
+	parts := make([]string, len(order))
+	for i, e := range order {
+		parts[i] = fmt.Sprintf("%s %d", e, counts[e])
+	}
+	return strings.Join(parts, "  ")
+}
+
+type reactionRequest struct {
+	ReactionType string `json:"reactionType"`
+}
+
+// SetReaction adds the signed-in user's reaction to a message.
+func (c *Client) SetReaction(ctx context.Context, chatID, messageID, reactionType string) error {
+	return c.postReaction(ctx, chatID, messageID, "setReaction", reactionType)
+}
+
+// UnsetReaction removes the signed-in user's reaction from a message.
+func (c *Client) UnsetReaction(ctx context.Context, chatID, messageID, reactionType string) error {
+	return c.postReaction(ctx, chatID, messageID, "unsetReaction", reactionType)
+}
+
+func (c *Client) postReaction(ctx context.Context, chatID, messageID, action, reactionType string) error {
+	if c.appOnly {
+		return userRequired("reacting to messages")
+	}
+	data, err := json.Marshal(reactionRequest{ReactionType: reactionType})
+	if err != nil {
+		return fmt.Errorf("marshalling reaction: %w", err)
+	}
+
+	// Setting or removing the same reaction twice has the same effect as once.
+	path := fmt.Sprintf("/chats/%s/messages/%s/%s", url.PathEscape(chatID), url.PathEscape(messageID), action)
+	resp, err := c.doIdempotent(ctx, "POST", path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,68 @@
+package graph
+
+import "testing"
+
+func TestReactionSummary(t *testing.T) {
+	tests := []struct {
+		name      string
+		reactions []Reaction
+		want      string
+	}{
+		{
+			name: "no reactions",
+			want: "",
+		},
+		{
+			name:      "counts grouped in order of first use",
+			reactions: []Reaction{{ReactionType: "✅"}, {ReactionType: "👍"}, {ReactionType: "✅"}},
+			want:      "✅ 2  👍 1",
+		},
+		{
+			name:      "legacy names are shown as emoji",
+			reactions: []Reaction{{ReactionType: "like"}, {ReactionType: "👍"}, {ReactionType: "heart"}},
+			want:      "👍 2  ❤️ 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReactionSummary(Message{Reactions: tt.reactions})
+			if got != tt.want {
+				t.Errorf("ReactionSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSenderName(t *testing.T) {
+	tests := []struct {
+		name string
+		from *MessageFrom
+		want string
+	}{
+		{name: "user", from: &MessageFrom{User: &Identity{DisplayName: "Alice"}}, want: "Alice"},
+		{name: "application", from: &MessageFrom{Application: &Identity{DisplayName: "CI Bot"}}, want: "CI Bot"},
+		{name: "system message", from: nil, want: "(system)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SenderName(Message{From: tt.from}); got != tt.want {
+				t.Errorf("SenderName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateImportance(t *testing.T) {
+	for _, ok := range []string{"", "normal", "high", "urgent"} {
+		if err := ValidateImportance(ok); err != nil {
+			t.Errorf("ValidateImportance(%q) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range []string{"Urgent", "low", "critical"} {
+		if err := ValidateImportance(bad); err == nil {
+			t.Errorf("ValidateImportance(%q) = nil, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// PageOptions controls a paged listing.
+type PageOptions struct {
+	// Top is the page size requested with $top; zero leaves it to Graph or
+	// to the path.
+	Top int
+	// MaxItems stops the listing after this many items; zero means all.
+	MaxItems int
+}
+
+type page[T any] struct {
+	Value    []T    `json:"value"`
+	NextLink string `json:"@odata.nextLink"`
+}
+
+// Paginate streams the items of a Graph collection, fetching the next page
+// only when the previous one has been consumed. path is the first page,
+// relative to the base URL; @odata.nextLink is followed to the end of the
+// collection. Since the access token goes with it, a link is only followed
+// on the base URL's host or, over HTTPS, on the Graph host of a Microsoft
+// cloud; any other link ends the sequence with an error.
+//
+// An error ends the sequence after being yielded once. Breaking out of the
+// loop stops fetching.
+func Paginate[T any](ctx context.Context, c *Client, path string, opts PageOptions) iter.Seq2[T, error] {
+	top := opts.Top
+	if opts.MaxItems > 0 && top > opts.MaxItems {
+		top = opts.MaxItems // no point fetching items that will be dropped
+	}
+	if top > 0 && !strings.Contains(path, "$top=") {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		path += sep + "$top=" + strconv.Itoa(top)
+	}
+
+	return func(yield func(T, error) bool) {
+		n := 0
+		for next := path; next != ""; {
+			p, err := getPage[T](ctx, c, next)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			c.log.Debug("fetched page", "path", next, "items", len(p.Value), "more", p.NextLink != "")
+
+			for _, item := range p.Value {
+				if !yield(item, nil) {
+					return
+				}
+				n++
+				if opts.MaxItems > 0 && n >= opts.MaxItems {
+					return
+				}
+			}
+			next = c.relative(p.NextLink)
+		}
+	}
+}
+
+func getPage[T any](ctx context.Context, c *Client, path string) (*page[T], error) {
+	resp, err := c.do(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	var p page[T]
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("parsing page: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,126 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/piotrwolkowski/tcli/graphtest"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      PageOptions
+		stopAfter int // break out of the loop after this many items; 0 reads all
+		wantItems int
+		wantPages int
+		wantTop   string
+	}{
+		{name: "all pages", opts: PageOptions{}, wantItems: 7, wantPages: 4},
+		{name: "top sets page size", opts: PageOptions{Top: 1}, wantItems: 7, wantPages: 7, wantTop: "1"},
+		{name: "max items", opts: PageOptions{MaxItems: 3}, wantItems: 3, wantPages: 2},
+		{name: "max items caps top", opts: PageOptions{Top: 50, MaxItems: 1}, wantItems: 1, wantPages: 1, wantTop: "1"},
+		{name: "early break", opts: PageOptions{}, stopAfter: 2, wantItems: 2, wantPages: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := fakeClient(t)
+			srv.SetPageSize(2)
+			for i := range 7 {
+				srv.AddChat(graphtest.Chat{ID: fmt.Sprintf("chat-%d", i)})
+			}
+
+			var got []Chat
+			for chat, err := range c.Chats(context.Background(), tt.opts) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, chat)
+				if len(got) == tt.stopAfter {
+					break
+				}
+			}
+
+			if len(got) != tt.wantItems {
+				t.Errorf("got %d items, want %d", len(got), tt.wantItems)
+			}
+			for i, chat := range got {
+				if want := fmt.Sprintf("chat-%d", i); chat.ID != want {
+					t.Errorf("item %d = %s, want %s", i, chat.ID, want)
+				}
+			}
+			reqs := srv.Requests()
+			if len(reqs) != tt.wantPages {
+				t.Errorf("fetched %d pages, want %d", len(reqs), tt.wantPages)
+			}
+			if len(reqs) > 0 && reqs[0].Query.Get("$top") != tt.wantTop {
+				t.Errorf("$top = %q, want %q", reqs[0].Query.Get("$top"), tt.wantTop)
+			}
+		})
+	}
+}
+
+func TestPaginateRefusesLinksToOtherHosts(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("followed a next link to another host")
+		fmt.Fprint(w, `{"value":[{"id":"chat-2"}]}`)
+	}))
+	defer other.Close()
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"value":[{"id":"chat-1"}],"@odata.nextLink":%q}`, other.URL+"/v1.0/me/chats?$skiptoken=x")
+	}))
+	defer first.Close()
+
+	c := NewClient(
+		WithBaseURL(first.URL+"/v1.0"),
+		WithTokenSource(TokenSourceFunc(func(context.Context) (string, error) { return "token", nil })),
+	)
+	_, err := c.ListChats(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("ListChats() error = %v, want refusal to follow the next link", err)
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	c := NewClient(WithBaseURL("https://graph.microsoft.com/v1.0"))
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "/me/chats", want: "https://graph.microsoft.com/v1.0/me/chats"},
+		{path: "https://graph.microsoft.com/v1.0/me/chats?$skiptoken=x", want: "https://graph.microsoft.com/v1.0/me/chats?$skiptoken=x"},
+		{path: "https://graph.microsoft.us/v1.0/me/chats", want: "https://graph.microsoft.us/v1.0/me/chats"},
+		{path: "http://graph.microsoft.com/v1.0/me/chats", wantErr: true},
+		{path: "https://graph.microsoft.com.example.com/v1.0/me/chats", wantErr: true},
+		{path: "https://example.com/v1.0/me/chats", wantErr: true},
+		{path: "https://graph.microsoft.com:8443/v1.0/me/chats", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := c.requestURL(tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("requestURL(%q) = %q, %v; want %q, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPaginateYieldsErrorOnce(t *testing.T) {
+	c, srv := fakeClient(t)
+	srv.Fail(graphtest.Fault{Path: "/me/chats", Status: http.StatusForbidden, Code: "Forbidden"})
+
+	n := 0
+	for _, err := range c.Chats(context.Background(), PageOptions{}) {
+		n++
+		if !IsStatus(err, http.StatusForbidden) {
+			t.Errorf("error = %v, want 403", err)
+		}
+	}
+	if n != 1 {
+		t.Errorf("sequence yielded %d times, want the error once", n)
+	}
+}
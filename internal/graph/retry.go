@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how Client retries throttled requests, server errors
+// and network failures.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 1 mean a single attempt.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; it doubles with each
+	// further retry. A Retry-After header from Graph takes precedence.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After longer than MaxDelay ends the
+	// retries instead of waiting. Zero means no cap.
+	MaxDelay time.Duration
+	// Jitter randomises each backoff by up to this fraction (0.2 = ±20%), so
+	// many clients throttled together do not retry in lockstep.
+	Jitter float64
+}
+
+// DefaultRetryPolicy waits 2s, 4s and 8s (±20%) between four attempts.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   2 * time.Second,
+	MaxDelay:    time.Minute,
+	Jitter:      0.2,
+}
+
+// NoRetry makes a single attempt.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// retryable reports whether a response with this status may be retried.
+// Requests that are not idempotent are only retried when Graph cannot have
+// acted on them: throttled (429) or refused as unavailable (503).
+func retryable(status int, idempotent bool) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// retryableNetErr reports whether a failed round trip may be retried. A
+// request that never reached Graph (the connection could not be opened) is
+// always safe to resend; anything else only for idempotent requests.
+func retryableNetErr(err error, idempotent bool) bool {
+	var op *net.OpError
+	if errors.As(err, &op) && op.Op == "dial" {
+		return true
+	}
+	return idempotent
+}
+
+// idempotentMethod reports whether repeating a request with this method has
+// the same effect as sending it once. Graph PATCH requests set fields to
+// given values, so they are treated as idempotent too.
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// delay returns how long to wait before retry number attempt+1, and false if
+// the server asked for a longer wait than the policy allows.
+func (p RetryPolicy) delay(resp *http.Response, attempt int) (time.Duration, bool) {
+	if d, ok := retryAfterHeader(resp, time.Now()); ok {
+		return d, p.MaxDelay <= 0 || d <= p.MaxDelay
+	}
+	d := p.BaseDelay << attempt
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		spread := float64(d) * p.Jitter
+		d += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+	return d, true
+}
+
+// retryAfterHeader parses Retry-After as either a number of seconds or an
+// HTTP date.
+func retryAfterHeader(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	h := resp.Header.Get("Retry-After")
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sleep waits for d unless ctx is done first. It fails straight away when
+// ctx's deadline would pass before the wait is over, rather than sleeping
+// only to be cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedTransport answers each request with the next scripted status, or
+// fails it with a network error when the status is 0.
+type scriptedTransport struct {
+	statuses []int
+	netErr   error
+	calls    int
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := s.statuses[min(s.calls, len(s.statuses)-1)]
+	s.calls++
+	if status == 0 {
+		return nil, s.netErr
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"id":"m1"}`)),
+		Request:    req,
+	}, nil
+}
+
+func testClient(rt http.RoundTripper) *Client {
+	return NewClient(
+		WithHTTPClient(&http.Client{Transport: rt}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithTokenSource(TokenSourceFunc(func(context.Context) (string, error) { return "token", nil })),
+	)
+}
+
+func TestRetry(t *testing.T) {
+	resetErr := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		method    string
+		statuses  []int
+		netErr    error
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "success", method: "GET", statuses: []int{200}, wantCalls: 1},
+		{name: "GET retries 500", method: "GET", statuses: []int{500, 502, 200}, wantCalls: 3},
+		{name: "GET retries 504 until attempts run out", method: "GET", statuses: []int{504}, wantCalls: 3, wantErr: true},
+		{name: "GET retries network error", method: "GET", statuses: []int{0, 200}, netErr: resetErr, wantCalls: 2},
+		{name: "POST retries 429", method: "POST", statuses: []int{429, 200}, wantCalls: 2},
+		{name: "POST retries 503", method: "POST", statuses: []int{503, 200}, wantCalls: 2},
+		{name: "POST does not retry 500", method: "POST", statuses: []int{500, 200}, wantCalls: 1, wantErr: true},
+		{name: "POST does not retry reset connection", method: "POST", statuses: []int{0, 200}, netErr: resetErr, wantCalls: 1, wantErr: true},
+		{name: "POST retries failed dial", method: "POST", statuses: []int{0, 200}, netErr: dialErr, wantCalls: 2},
+		{name: "4xx is not retried", method: "GET", statuses: []int{404}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &scriptedTransport{statuses: tt.statuses, netErr: tt.netErr}
+			resp, err := testClient(rt).do(context.Background(), tt.method, "/me/chats", nil)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if rt.calls != tt.wantCalls {
+				t.Errorf("made %d attempts, want %d", rt.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	c := testClient(&scriptedTransport{statuses: []int{503}})
+	c.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	_, err := c.do(ctx, "GET", "/me/chats", nil)
+	if err == nil || !IsTransient(err) {
+		t.Fatalf("err = %v, want the transient 503 error", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("waited for a retry that could not finish before the deadline")
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "seconds", header: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "HTTP date", header: "Mon, 19 Oct 2026 12:00:45 GMT", want: 45 * time.Second, wantOK: true},
+		{name: "date in the past", header: "Mon, 19 Oct 2026 11:00:00 GMT", want: 0, wantOK: true},
+		{name: "garbage", header: "soon", wantOK: false},
+		{name: "missing", header: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := makeRespWithHeader(503, "", "Retry-After", tt.header)
+			got, ok := retryAfterHeader(resp, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfterHeader(%q) = %v, %v; want %v, %v", tt.header, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second, Jitter: 0.5}
+	for attempt := range 6 {
+		d, ok := p.delay(makeResp(503, ""), attempt)
+		base := min(time.Second<<attempt, 10*time.Second)
+		if !ok || d < base/2 || d > base*3/2 {
+			t.Errorf("attempt %d: delay = %v, want within ±50%% of %v", attempt, d, base)
+		}
+	}
+
+	if _, ok := p.delay(makeRespWithHeader(429, "", "Retry-After", "3600"), 0); ok {
+		t.Error("Retry-After beyond MaxDelay should end retries")
+	}
+}
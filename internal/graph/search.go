@@ -0,0 +1,188 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SearchQuery describes a full-text search over chat messages.
+type SearchQuery struct {
+	Query string
+	// From restricts results to a sender name or email address.
+	From string
+	// ChatID restricts results to one chat. The Search API cannot filter on
+	// chats, so this is applied to each page of results.
+	ChatID string
+	Since  time.Time
+	Until  time.Time
+}
+
+// KQL returns the query string sent to the Search API, with the sender and
+// date filters expressed as KQL property restrictions.
+func (q SearchQuery) KQL() string {
+	parts := []string{q.Query}
+	if q.From != "" {
+		parts = append(parts, fmt.Sprintf("from:%q", q.From))
+	}
+	if !q.Since.IsZero() {
+		parts = append(parts, "sent>="+q.Since.UTC().Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		parts = append(parts, "sent<="+q.Until.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(parts, " ")
+}
+
+// SearchHit is one message matched by a search.
+type SearchHit struct {
+	MessageID   string `json:"messageId"`
+	ChatID      string `json:"chatId"`
+	Sender      string `json:"sender"`
+	SenderEmail string `json:"senderEmail"`
+	CreatedAt   string `json:"createdDateTime"`
+	// Summary is the matching snippet; matched terms are wrapped in
+	// <c0>...</c0> and elisions are marked with <ddd/>.
+	Summary string `json:"summary"`
+	WebURL  string `json:"webUrl"`
+}
+
+type searchRequest struct {
+	Requests []searchRequestItem `json:"requests"`
+}
+
+type searchRequestItem struct {
+	EntityTypes []string `json:"entityTypes"`
+	Query       struct {
+		QueryString string `json:"queryString"`
+	} `json:"query"`
+	From int `json:"from"`
+	Size int `json:"size"`
+}
+
+type searchResponse struct {
+	Value []struct {
+		HitsContainers []struct {
+			Hits []struct {
+				HitID    string `json:"hitId"`
+				Summary  string `json:"summary"`
+				Resource struct {
+					ID        string `json:"id"`
+					ChatID    string `json:"chatId"`
+					CreatedAt string `json:"createdDateTime"`
+					WebURL    string `json:"webUrl"`
+					WebLink   string `json:"webLink"`
+					From      struct {
+						EmailAddress struct {
+							Name    string `json:"name"`
+							Address string `json:"address"`
+						} `json:"emailAddress"`
+					} `json:"from"`
+				} `json:"resource"`
+			} `json:"hits"`
+			Total                int  `json:"total"`
+			MoreResultsAvailable bool `json:"moreResultsAvailable"`
+		} `json:"hitsContainers"`
+	} `json:"value"`
+}
+
+// SearchMessagesPage runs one page of a chat message search starting at
+// offset from. It returns the hits and whether more results are available.
+func (c *Client) SearchMessagesPage(ctx context.Context, q SearchQuery, from, size int) ([]SearchHit, bool, error) {
+	// The Search API only searches on behalf of a user.
+	if c.appOnly {
+		return nil, false, userRequired("searching messages")
+	}
+	item := searchRequestItem{EntityTypes: []string{"chatMessage"}, From: from, Size: size}
+	item.Query.QueryString = q.KQL()
+
+	data, err := json.Marshal(searchRequest{Requests: []searchRequestItem{item}})
+	if err != nil {
+		return nil, false, fmt.Errorf("marshalling search request: %w", err)
+	}
+
+	resp, err := c.doIdempotent(ctx, "POST", "/search/query", bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading response: %w", err)
+	}
+
+	var result searchResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, false, fmt.Errorf("parsing search response: %w", err)
+	}
+
+	var hits []SearchHit
+	more := false
+	for _, v := range result.Value {
+		for _, hc := range v.HitsContainers {
+			more = more || hc.MoreResultsAvailable
+			for _, h := range hc.Hits {
+				r := h.Resource
+				id := r.ID
+				if id == "" {
+					id = h.HitID
+				}
+				hit := SearchHit{
+					MessageID:   id,
+					ChatID:      r.ChatID,
+					Sender:      r.From.EmailAddress.Name,
+					SenderEmail: r.From.EmailAddress.Address,
+					CreatedAt:   r.CreatedAt,
+					Summary:     h.Summary,
+					WebURL:      r.WebURL,
+				}
+				if hit.WebURL == "" {
+					hit.WebURL = r.WebLink
+				}
+				if hit.WebURL == "" && hit.ChatID != "" {
+					hit.WebURL = MessageLink(hit.ChatID, hit.MessageID)
+				}
+				hits = append(hits, hit)
+			}
+		}
+	}
+	c.log.Debug("fetched page of search results", "from", from, "items", len(hits), "more", more)
+	return hits, more, nil
+}
+
+// SearchMessages pages through search results until limit hits matching q
+// have been collected or the results run out.
+func (c *Client) SearchMessages(ctx context.Context, q SearchQuery, limit int) ([]SearchHit, error) {
+	const pageSize = 25
+	var hits []SearchHit
+	for from := 0; ; from += pageSize {
+		page, more, err := c.SearchMessagesPage(ctx, q, from, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range page {
+			if q.ChatID != "" && h.ChatID != q.ChatID {
+				continue
+			}
+			hits = append(hits, h)
+			if limit > 0 && len(hits) >= limit {
+				return hits, nil
+			}
+		}
+		if !more || len(page) == 0 {
+			return hits, nil
+		}
+	}
+}
+
+// MessageLink returns a Teams deep link that opens a chat at a message.
+func MessageLink(chatID, messageID string) string {
+	return fmt.Sprintf("https://teams.microsoft.com/l/message/%s/%s?context=%s",
+		url.PathEscape(chatID), url.PathEscape(messageID), url.QueryEscape(`{"contextType":"chat"}`))
+}
@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchQueryKQL(t *testing.T) {
+	tests := []struct {
+		name string
+		q    SearchQuery
+		want string
+	}{
+		{
+			name: "query only",
+			q:    SearchQuery{Query: "kubectl"},
+			want: "kubectl",
+		},
+		{
+			name: "sender filter is quoted",
+			q:    SearchQuery{Query: "kubectl", From: "Alice Smith"},
+			want: `kubectl from:"Alice Smith"`,
+		},
+		{
+			name: "date range",
+			q: SearchQuery{
+				Query: "deploy",
+				Since: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+				Until: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			},
+			want: "deploy sent>=2026-09-01T00:00:00Z sent<=2026-10-01T00:00:00Z",
+		},
+		{
+			name: "chat filter is not part of KQL",
+			q:    SearchQuery{Query: "x", ChatID: "19:abc@thread.v2"},
+			want: "x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.q.KQL(); got != tt.want {
+				t.Errorf("KQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageLink(t *testing.T) {
+	got := MessageLink("19:abc@thread.v2", "1700000000000")
+	want := "https://teams.microsoft.com/l/message/19:abc@thread.v2/1700000000000?context=%7B%22contextType%22%3A%22chat%22%7D"
+	if got != want {
+		t.Errorf("MessageLink() = %q, want %q", got, want)
+	}
+}
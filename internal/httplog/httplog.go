@@ -0,0 +1,115 @@
+// Package httplog traces HTTP requests for --debug: method, URL, status,
+// timing, the Microsoft request ID headers and truncated bodies, with
+// credentials redacted.
+package httplog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/redact"
+)
+
+// DefaultMaxBody is how many bytes of each body are shown.
+const DefaultMaxBody = 2048
+
+// idHeaders are the headers Microsoft support asks for when investigating
+// a request.
+var idHeaders = []string{"request-id", "client-request-id", "x-ms-request-id", "x-ms-ags-diagnostic"}
+
+// Transport is an http.RoundTripper that writes a trace of each request and
+// response to Out.
+type Transport struct {
+	Next    http.RoundTripper
+	Out     io.Writer
+	MaxBody int
+
+	mu sync.Mutex
+}
+
+// NewTransport traces requests sent through next (http.DefaultTransport if
+// nil) to out.
+func NewTransport(next http.RoundTripper, out io.Writer) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{Next: next, Out: out, MaxBody: DefaultMaxBody}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--> %s %s\n", req.Method, redact.String(req.URL.String()))
+	writeIDs(&b, req.Header)
+	t.writeBody(&b, reqBody)
+
+	start := time.Now()
+	resp, err := t.Next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(&b, "<-- error after %s: %s\n", elapsed, redact.String(err.Error()))
+		t.flush(b.String())
+		return nil, err
+	}
+
+	fmt.Fprintf(&b, "<-- %s (%s)\n", resp.Status, elapsed)
+	writeIDs(&b, resp.Header)
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		fmt.Fprintf(&b, "    Retry-After: %s\n", ra)
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		// Passing on what was read would turn a truncated response into a
+		// successful, shorter one.
+		fmt.Fprintf(&b, "<-- error reading body after %d bytes: %s\n", len(respBody), redact.String(readErr.Error()))
+		t.flush(b.String())
+		return nil, readErr
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	t.writeBody(&b, respBody)
+	t.flush(b.String())
+	return resp, nil
+}
+
+func writeIDs(b *strings.Builder, h http.Header) {
+	for _, name := range idHeaders {
+		if v := h.Get(name); v != "" {
+			fmt.Fprintf(b, "    %s: %s\n", name, v)
+		}
+	}
+}
+
+func (t *Transport) writeBody(b *strings.Builder, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	s := redact.String(string(body))
+	if t.MaxBody > 0 && len(s) > t.MaxBody {
+		s = fmt.Sprintf("%s… (%d more bytes)", s[:t.MaxBody], len(s)-t.MaxBody)
+	}
+	fmt.Fprintf(b, "    %s\n", strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n    "))
+}
+
+// flush writes one complete trace so concurrent requests do not interleave.
+func (t *Transport) flush(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.Out, s)
+}
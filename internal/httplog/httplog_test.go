@@ -0,0 +1,66 @@
+package httplog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("request-id", "req-123")
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":{"code":"NotFound"},"padding":"`+strings.Repeat("x", 100)+`"}`)
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	tr := NewTransport(nil, &out)
+	tr.MaxBody = 40
+
+	req, _ := http.NewRequest("POST", srv.URL+"/token", strings.NewReader("grant_type=refresh_token&refresh_token=secret-value"))
+	req.Header.Set("Authorization", "Bearer secret-bearer")
+	req.Header.Set("client-request-id", "client-456")
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "NotFound") {
+		t.Errorf("response body was not passed through: %q", body)
+	}
+
+	trace := out.String()
+	for _, want := range []string{"--> POST " + srv.URL + "/token", "client-request-id: client-456", "<-- 404 Not Found", "request-id: req-123", "more bytes)"} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace missing %q:\n%s", want, trace)
+		}
+	}
+	for _, secret := range []string{"secret-value", "secret-bearer"} {
+		if strings.Contains(trace, secret) {
+			t.Errorf("trace leaks %q:\n%s", secret, trace)
+		}
+	}
+}
+
+func TestTransportTruncatedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		io.WriteString(w, `{"value":[`)
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	resp, err := (&http.Client{Transport: NewTransport(nil, &out)}).Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("truncated response was passed on as a success")
+	}
+	if !strings.Contains(out.String(), "error reading body after 10 bytes") {
+		t.Errorf("trace does not report the truncated body:\n%s", out.String())
+	}
+}
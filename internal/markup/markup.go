@@ -0,0 +1,280 @@
+// Package markup converts the HTML bodies of Teams messages into text
+// suitable for a terminal.
+package markup
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	blockTag   = regexp.MustCompile(`(?i)<\s*(br|/p|/div|/li|/tr|/h[1-6])\s*/?\s*>`)
+	anyTag     = regexp.MustCompile(`<[^>]*>`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// PlainText strips HTML tags from a message body, turning block-level
+// elements into line breaks and decoding entities. Plain-text bodies pass
+// through unchanged apart from entity decoding. Control characters other
+// than newlines and tabs are removed, so a message cannot send escape
+// sequences to the terminal it is printed on.
+func PlainText(body string) string {
+	s := strings.ReplaceAll(body, "\r\n", "\n")
+	s = blockTag.ReplaceAllString(s, "\n")
+	s = anyTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	s = strings.Map(dropControl, s)
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// dropControl is a strings.Map function removing C0 and C1 control
+// characters, DEL included, except newline and tab.
+func dropControl(r rune) rune {
+	if r == '\n' || r == '\t' {
+		return r
+	}
+	if r < 0x20 || r >= 0x7f && r < 0xa0 {
+		return -1
+	}
+	return r
+}
+
+var (
+	tagPattern  = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*?)(/?)>`)
+	hrefPattern = regexp.MustCompile(`(?i)\bhref\s*=\s*("([^"]*)"|'([^']*)')`)
+	srcPattern  = regexp.MustCompile(`(?i)\bsrc\s*=\s*("([^"]*)"|'([^']*)')`)
+	altPattern  = regexp.MustCompile(`(?i)\balt\s*=\s*("([^"]*)"|'([^']*)')`)
+	spaceRun    = regexp.MustCompile(`[ \t\r\n]+`)
+)
+
+// Markdown converts a Teams HTML message body to Markdown. It understands the
+// formatting the Teams composer produces (emphasis, code, links, lists,
+// quotes, headings, images and mentions) and drops anything else to text.
+func Markdown(body string) string {
+	var b strings.Builder
+	var links []string
+	listDepth := 0
+	inPre := false
+	quoteDepth := 0
+
+	newline := func() {
+		b.WriteString("\n")
+		if quoteDepth > 0 {
+			b.WriteString(strings.Repeat("> ", quoteDepth))
+		}
+	}
+
+	text := func(s string) {
+		s = html.UnescapeString(s)
+		s = strings.ReplaceAll(s, " ", " ")
+		if inPre {
+			b.WriteString(s)
+			return
+		}
+		b.WriteString(spaceRun.ReplaceAllString(s, " "))
+	}
+
+	pos := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(body, -1) {
+		text(body[pos:m[0]])
+		pos = m[1]
+
+		closing := m[3] > m[2]
+		name := strings.ToLower(body[m[4]:m[5]])
+		attrs := body[m[6]:m[7]]
+
+		switch name {
+		case "b", "strong":
+			b.WriteString("**")
+		case "i", "em":
+			b.WriteString("_")
+		case "s", "strike", "del":
+			b.WriteString("~~")
+		case "code":
+			if !inPre {
+				b.WriteString("`")
+			}
+		case "pre":
+			if closing {
+				inPre = false
+				b.WriteString("\n```")
+				newline()
+			} else {
+				newline()
+				b.WriteString("```\n")
+				inPre = true
+			}
+		case "a":
+			if closing {
+				if n := len(links); n > 0 {
+					if links[n-1] != "" {
+						b.WriteString("](" + links[n-1] + ")")
+					}
+					links = links[:n-1]
+				}
+			} else {
+				href := attrValue(hrefPattern, attrs)
+				links = append(links, href)
+				if href != "" {
+					b.WriteString("[")
+				}
+			}
+		case "img":
+			if src := attrValue(srcPattern, attrs); src != "" {
+				b.WriteString("![" + attrValue(altPattern, attrs) + "](" + src + ")")
+			}
+		case "br":
+			newline()
+		case "p", "div":
+			newline()
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			newline()
+			newline()
+			if !closing {
+				b.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+			}
+		case "ul", "ol":
+			if closing {
+				listDepth--
+			} else {
+				listDepth++
+			}
+			newline()
+		case "li":
+			if !closing {
+				newline()
+				b.WriteString(strings.Repeat("  ", max(listDepth-1, 0)) + "- ")
+			}
+		case "blockquote":
+			if closing {
+				quoteDepth--
+				newline()
+			} else {
+				quoteDepth++
+			}
+			newline()
+		}
+	}
+	text(body[pos:])
+
+	lines := strings.Split(b.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	s := strings.Join(lines, "\n")
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+func attrValue(re *regexp.Regexp, attrs string) string {
+	m := re.FindStringSubmatch(attrs)
+	if m == nil {
+		return ""
+	}
+	if m[2] != "" {
+		return html.UnescapeString(m[2])
+	}
+	return html.UnescapeString(m[3])
+}
+
+// safeTags are the elements SafeHTML keeps: the formatting the Teams
+// composer produces. Their attributes are dropped, except for safeAttrs.
+var safeTags = map[string]bool{
+	"a": true, "b": true, "blockquote": true, "br": true, "code": true, "del": true, "div": true,
+	"em": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true,
+	"i": true, "img": true, "li": true, "ol": true, "p": true, "pre": true, "s": true, "span": true,
+	"strike": true, "strong": true, "sub": true, "sup": true, "table": true, "tbody": true, "td": true,
+	"th": true, "thead": true, "tr": true, "u": true, "ul": true,
+}
+
+var safeAttrs = map[string][]struct {
+	name string
+	re   *regexp.Regexp
+}{
+	"a":   {{"href", hrefPattern}},
+	"img": {{"src", srcPattern}, {"alt", altPattern}},
+}
+
+// SafeHTML rebuilds a Teams HTML message body from an allow-list, so it can
+// be embedded in an HTML page without running anything a chat member
+// posted. Allowed tags are re-emitted with only their allowed attributes;
+// links must be http, https or mailto URLs. Other tags are dropped, along
+// with the content of script and style elements, and all text is escaped.
+func SafeHTML(body string) string {
+	var b strings.Builder
+	skip := "" // script or style element being dropped
+
+	pos := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(body, -1) {
+		if skip == "" {
+			b.WriteString(html.EscapeString(html.UnescapeString(body[pos:m[0]])))
+		}
+		pos = m[1]
+
+		closing := m[3] > m[2]
+		name := strings.ToLower(body[m[4]:m[5]])
+		attrs := body[m[6]:m[7]]
+
+		switch {
+		case skip != "":
+			if closing && name == skip {
+				skip = ""
+			}
+		case name == "script" || name == "style":
+			if !closing {
+				skip = name
+			}
+		case !safeTags[name]:
+		case closing:
+			b.WriteString("</" + name + ">")
+		default:
+			b.WriteString("<" + name)
+			for _, a := range safeAttrs[name] {
+				v := attrValue(a.re, attrs)
+				if v == "" || a.name != "alt" && !safeURL(v) {
+					continue
+				}
+				b.WriteString(" " + a.name + `="` + html.EscapeString(v) + `"`)
+			}
+			b.WriteString(">")
+		}
+	}
+	if skip == "" {
+		b.WriteString(html.EscapeString(html.UnescapeString(body[pos:])))
+	}
+	return b.String()
+}
+
+// safeURL reports whether a link target is a web or mail address rather
+// than, say, a javascript: URL.
+func safeURL(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, scheme := range []string{"https:", "http:", "mailto:"} {
+		if strings.HasPrefix(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	hitStart = regexp.MustCompile(`(?i)<c0>`)
+	hitEnd   = regexp.MustCompile(`(?i)</c0>`)
+	elision  = regexp.MustCompile(`(?i)<ddd\s*/>`)
+)
+
+// Highlight renders a Microsoft Search result summary as text, wrapping the
+// matched terms (marked <c0>...</c0> by the API) in start and end.
+func Highlight(summary, start, end string) string {
+	// Private-use characters mark the hits, as PlainText drops controls.
+	s := hitStart.ReplaceAllString(summary, "\ue000")
+	s = hitEnd.ReplaceAllString(s, "\ue001")
+	s = elision.ReplaceAllString(s, "…")
+	s = PlainText(s)
+	s = spaceRun.ReplaceAllString(s, " ")
+	s = strings.ReplaceAll(s, "\ue000", start)
+	return strings.ReplaceAll(s, "\ue001", end)
+}
@@ -0,0 +1,93 @@
+package markup
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain text unchanged", in: "hello", want: "hello"},
+		{name: "paragraphs become lines", in: "<p>one</p><p>two</p>", want: "one\ntwo"},
+		{name: "br becomes newline", in: "a<br>b<br/>c", want: "a\nb\nc"},
+		{name: "inline tags stripped", in: "<p>see <b>this</b> <a href=\"x\">link</a></p>", want: "see this link"},
+		{name: "entities decoded", in: "a &amp; b&nbsp;&lt;c&gt;", want: "a & b <c>"},
+		{name: "runs of blank lines collapsed", in: "<div>a</div><br><br><br><div>b</div>", want: "a\n\nb"},
+		{name: "escape sequences removed", in: "a\x1b]52;c;cHduZWQ=\x07b\x1b[2J\tc\u009b31m", want: "a]52;c;cHduZWQ=b[2J\tc31m"},
+		{name: "encoded controls removed", in: "<p>x&#27;[1;1Hy&#7;</p>", want: "x[1;1Hy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlainText(tt.in); got != tt.want {
+				t.Errorf("PlainText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain text", in: "hello", want: "hello"},
+		{name: "emphasis", in: "<p><b>bold</b> and <i>italic</i> and <s>gone</s></p>", want: "**bold** and _italic_ and ~~gone~~"},
+		{name: "inline code", in: "run <code>kubectl get pods</code>", want: "run `kubectl get pods`"},
+		{name: "code block keeps whitespace", in: "<pre><code>a\n  b</code></pre>", want: "```\na\n  b\n```"},
+		{name: "link", in: `see <a href="https://x.test/?a=1&amp;b=2">docs</a>`, want: "see [docs](https://x.test/?a=1&b=2)"},
+		{name: "image", in: `<img src="https://x.test/i.png" alt="chart">`, want: "![chart](https://x.test/i.png)"},
+		{name: "paragraphs", in: "<p>one</p><p>two</p>", want: "one\n\ntwo"},
+		{name: "list", in: "<ul><li>a</li><li>b</li></ul>", want: "- a\n- b"},
+		{name: "quote", in: "<blockquote>quoted</blockquote>after", want: "> quoted\n\nafter"},
+		{name: "heading", in: "<h2>Title</h2>text", want: "## Title\n\ntext"},
+		{name: "mention becomes text", in: `<at id="0">Alice</at> ping`, want: "Alice ping"},
+		{name: "whitespace collapsed", in: "<p>a\n   b</p>", want: "a b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Markdown(tt.in); got != tt.want {
+				t.Errorf("Markdown(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "formatting kept", in: "<p>Pods are <b>crashing</b></p>", want: "<p>Pods are <b>crashing</b></p>"},
+		{name: "attributes dropped", in: `<p style="color:red" onclick="steal()">hi</p>`, want: "<p>hi</p>"},
+		{name: "link kept", in: `<a href="https://x.test/?a=1&amp;b=2" target="_blank">docs</a>`, want: `<a href="https://x.test/?a=1&amp;b=2">docs</a>`},
+		{name: "javascript link dropped", in: `<a href=" JavaScript:alert(1)">x</a>`, want: "<a>x</a>"},
+		{name: "image", in: `<img src="https://x.test/i.png" alt="a &quot;chart&quot;" onerror="steal()">`, want: `<img src="https://x.test/i.png" alt="a &#34;chart&#34;">`},
+		{name: "script dropped with its content", in: "a<script>alert(1)</script>b", want: "ab"},
+		{name: "unknown tags dropped", in: `<iframe src="https://x.test"></iframe><svg onload="x()">t</svg>`, want: "t"},
+		{name: "stray brackets escaped", in: `1 < 2 <!-- <b> --> x"><img src=x onerror=y>`, want: "1 &lt; 2 &lt;!-- <b> --&gt; x&#34;&gt;<img>"},
+		{name: "entities escaped once", in: "a &amp; b &lt;i&gt;", want: "a &amp; b &lt;i&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeHTML(tt.in); got != tt.want {
+				t.Errorf("SafeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	summary := "run <c0>kubectl</c0> get pods<ddd/> then <c0>Kubectl</c0> logs &amp; exit"
+	if got, want := Highlight(summary, "[", "]"), "run [kubectl] get pods… then [Kubectl] logs & exit"; got != want {
+		t.Errorf("Highlight() = %q, want %q", got, want)
+	}
+	if got, want := Highlight(summary, "", ""), "run kubectl get pods… then Kubectl logs & exit"; got != want {
+		t.Errorf("Highlight() = %q, want %q", got, want)
+	}
+}
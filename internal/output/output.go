@@ -0,0 +1,257 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Supported values for Options.Format. Template and JSONPath formats carry
+// their expression after the "=" (e.g. "go-template={{.id}}").
+const (
+	FormatTable      = "table"
+	FormatJSON       = "json"
+	FormatNDJSON     = "ndjson"
+	FormatYAML       = "yaml"
+	FormatCSV        = "csv"
+	FormatGoTemplate = "go-template"
+	FormatJSONPath   = "jsonpath"
+)
+
+// Formats lists the accepted format names, for flag help text.
+var Formats = []string{FormatTable, FormatJSON, FormatNDJSON, FormatYAML, FormatCSV, FormatGoTemplate + "=...", FormatJSONPath + "=..."}
+
+// Column describes one column of tabular output. Name is the key accepted by
+// --columns; Header is what is printed in the header row.
+type Column[T any] struct {
+	Name   string
+	Header string
+	Value  func(T) string
+}
+
+// Options controls how items are rendered.
+type Options struct {
+	Format    string
+	NoHeaders bool
+	Columns   []string
+}
+
+// Print renders items to w in the format selected by opts. Table and CSV
+// output use cols; every other format works from the JSON encoding of items,
+// so structured formats always expose the full object.
+func Print[T any](w io.Writer, opts Options, items []T, cols []Column[T]) error {
+	format, arg, _ := strings.Cut(opts.Format, "=")
+	switch format {
+	case "", FormatTable:
+		cols, err := selectColumns(cols, opts.Columns)
+		if err != nil {
+			return err
+		}
+		return printTable(w, opts, items, cols)
+	case FormatCSV:
+		cols, err := selectColumns(cols, opts.Columns)
+		if err != nil {
+			return err
+		}
+		return printCSV(w, opts, items, cols)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if items == nil {
+			items = []T{}
+		}
+		return enc.Encode(items)
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatYAML:
+		v, err := toGeneric(items)
+		if err != nil {
+			return err
+		}
+		return writeYAML(w, v)
+	case FormatGoTemplate:
+		return printTemplate(w, arg, items)
+	case FormatJSONPath:
+		return printJSONPath(w, arg, items)
+	}
+	return fmt.Errorf("unknown output format %q (supported: %s)", opts.Format, strings.Join(Formats, ", "))
+}
+
+// Validate reports whether format is a recognised output format, so commands
+// can fail before doing any work.
+func Validate(format string) error {
+	name, arg, hasArg := strings.Cut(format, "=")
+	switch name {
+	case "", FormatTable, FormatJSON, FormatNDJSON, FormatYAML, FormatCSV:
+		if hasArg {
+			return fmt.Errorf("output format %q does not take an argument", name)
+		}
+		return nil
+	case FormatGoTemplate:
+		if arg == "" {
+			return fmt.Errorf("go-template output requires a template, e.g. -o 'go-template={{.id}}'")
+		}
+		_, err := template.New("output").Parse(arg)
+		return err
+	case FormatJSONPath:
+		if arg == "" {
+			return fmt.Errorf("jsonpath output requires a path, e.g. -o jsonpath=.id")
+		}
+		_, err := parsePath(arg)
+		return err
+	}
+	return fmt.Errorf("unknown output format %q (supported: %s)", format, strings.Join(Formats, ", "))
+}
+
+func selectColumns[T any](cols []Column[T], names []string) ([]Column[T], error) {
+	if len(names) == 0 {
+		return cols, nil
+	}
+	var selected []Column[T]
+	for _, name := range names {
+		found := false
+		for _, c := range cols {
+			if strings.EqualFold(c.Name, strings.TrimSpace(name)) {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			var valid []string
+			for _, c := range cols {
+				valid = append(valid, c.Name)
+			}
+			return nil, fmt.Errorf("unknown column %q (available: %s)", name, strings.Join(valid, ", "))
+		}
+	}
+	return selected, nil
+}
+
+func printTable[T any](w io.Writer, opts Options, items []T, cols []Column[T]) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if !opts.NoHeaders {
+		headers := make([]string, len(cols))
+		for i, c := range cols {
+			headers[i] = c.Header
+		}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, item := range items {
+		fields := make([]string, len(cols))
+		for i, c := range cols {
+			// Tabs and newlines would break column alignment.
+			fields[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(c.Value(item))
+		}
+		fmt.Fprintln(tw, strings.Join(fields, "\t"))
+	}
+	return tw.Flush()
+}
+
+func printCSV[T any](w io.Writer, opts Options, items []T, cols []Column[T]) error {
+	cw := csv.NewWriter(w)
+	if !opts.NoHeaders {
+		headers := make([]string, len(cols))
+		for i, c := range cols {
+			headers[i] = c.Name
+		}
+		if err := cw.Write(headers); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		fields := make([]string, len(cols))
+		for i, c := range cols {
+			fields[i] = c.Value(item)
+		}
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// printTemplate executes tmpl once per item against the item's JSON form, so
+// templates use the same field names as JSON output.
+func printTemplate[T any](w io.Writer, tmpl string, items []T) error {
+	t, err := template.New("output").Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	for _, item := range items {
+		v, err := toGeneric(item)
+		if err != nil {
+			return err
+		}
+		if err := t.Execute(w, v); err != nil {
+			return fmt.Errorf("executing template: %w", err)
+		}
+		if !strings.HasSuffix(tmpl, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
+
+func printJSONPath[T any](w io.Writer, expr string, items []T) error {
+	p, err := parsePath(expr)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		v, err := toGeneric(item)
+		if err != nil {
+			return err
+		}
+		for _, res := range p.eval(v) {
+			fmt.Fprintln(w, scalarString(res))
+		}
+	}
+	return nil
+}
+
+// toGeneric round-trips v through JSON so formatters can walk it without
+// reflection. Numbers are kept as json.Number to avoid float formatting.
+func toGeneric(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encoding output: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("encoding output: %w", err)
+	}
+	return out, nil
+}
+
+func scalarString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		if x {
+			return "true"
+		}
+		return "false"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
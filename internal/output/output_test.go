@@ -0,0 +1,128 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+var testColumns = []Column[item]{
+	{Name: "id", Header: "ID", Value: func(i item) string { return i.ID }},
+	{Name: "name", Header: "NAME", Value: func(i item) string { return i.Name }},
+}
+
+var testItems = []item{
+	{ID: "1", Name: "Alpha", Tags: []string{"a", "b"}},
+	{ID: "2", Name: "Beta, Gamma"},
+}
+
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "table with headers",
+			opts: Options{Format: FormatTable},
+			want: "ID  NAME\n1   Alpha\n2   Beta, Gamma\n",
+		},
+		{
+			name: "table without headers and selected columns",
+			opts: Options{Format: FormatTable, NoHeaders: true, Columns: []string{"name"}},
+			want: "Alpha\nBeta, Gamma\n",
+		},
+		{
+			name: "csv quotes fields and uses column names",
+			opts: Options{Format: FormatCSV},
+			want: "id,name\n1,Alpha\n2,\"Beta, Gamma\"\n",
+		},
+		{
+			name: "ndjson emits one object per line",
+			opts: Options{Format: FormatNDJSON},
+			want: "{\"id\":\"1\",\"name\":\"Alpha\",\"tags\":[\"a\",\"b\"]}\n{\"id\":\"2\",\"name\":\"Beta, Gamma\",\"tags\":null}\n",
+		},
+		{
+			name: "yaml",
+			opts: Options{Format: FormatYAML},
+			want: "- id: \"1\"\n  name: Alpha\n  tags:\n    - a\n    - b\n- id: \"2\"\n  name: \"Beta, Gamma\"\n  tags: null\n",
+		},
+		{
+			name: "go-template uses JSON field names",
+			opts: Options{Format: "go-template={{.id}}={{.name}}"},
+			want: "1=Alpha\n2=Beta, Gamma\n",
+		},
+		{
+			name: "jsonpath selects nested values",
+			opts: Options{Format: "jsonpath=.tags[*]"},
+			want: "a\nb\n",
+		},
+		{
+			name: "jsonpath with braces and index",
+			opts: Options{Format: "jsonpath={.tags[0]}"},
+			want: "a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Print(&buf, tt.opts, testItems, testColumns); err != nil {
+				t.Fatalf("Print() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Print() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintJSONEmptyIsArray(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Print(&buf, Options{Format: FormatJSON}, []item(nil), testColumns); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("Print() = %q, want []", got)
+	}
+}
+
+func TestPrintUnknownColumn(t *testing.T) {
+	err := Print(&bytes.Buffer{}, Options{Columns: []string{"nope"}}, testItems, testColumns)
+	if err == nil || !strings.Contains(err.Error(), "available: id, name") {
+		t.Errorf("Print() error = %v, want unknown column error listing columns", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"table", false},
+		{"", false},
+		{"yaml", false},
+		{"go-template={{.id}}", false},
+		{"jsonpath=.id", false},
+		{"xml", true},
+		{"json=foo", true},
+		{"go-template=", true},
+		{"go-template={{.id", true},
+		{"jsonpath=.a[x]", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			err := Validate(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path is a small JSONPath-like field selector: dotted field names with
+// optional [n] or [*] indexes, e.g. ".members[*].displayName". The leading
+// "$", "." and surrounding "{}" are optional.
+type path []pathStep
+
+type pathStep struct {
+	field string
+	index int
+	all   bool
+	isIdx bool
+}
+
+func parsePath(expr string) (path, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	s = strings.TrimPrefix(s, "$")
+	s = strings.TrimPrefix(s, ".")
+
+	var p path
+	for s != "" {
+		switch {
+		case s[0] == '.':
+			s = s[1:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", expr)
+			}
+			idx := s[1:end]
+			s = s[end+1:]
+			if idx == "*" {
+				p = append(p, pathStep{all: true, isIdx: true})
+				continue
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", expr, idx)
+			}
+			p = append(p, pathStep{index: n, isIdx: true})
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			p = append(p, pathStep{field: s[:end]})
+			s = s[end:]
+		}
+	}
+	return p, nil
+}
+
+// eval returns every value matched by p in v. Missing fields and
+// out-of-range indexes match nothing rather than failing.
+func (p path) eval(v any) []any {
+	current := []any{v}
+	for _, step := range p {
+		var next []any
+		for _, c := range current {
+			if !step.isIdx {
+				if m, ok := c.(map[string]any); ok {
+					if val, ok := m[step.field]; ok {
+						next = append(next, val)
+					}
+				}
+				continue
+			}
+			list, ok := c.([]any)
+			if !ok {
+				continue
+			}
+			if step.all {
+				next = append(next, list...)
+				continue
+			}
+			i := step.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				next = append(next, list[i])
+			}
+		}
+		current = next
+	}
+	return current
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeYAML emits v (as produced by toGeneric) as a YAML document. It covers
+// the subset of YAML needed for JSON-shaped data: maps, lists and scalars.
+func writeYAML(w io.Writer, v any) error {
+	var b strings.Builder
+	yamlValue(&b, v, 0, false)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func yamlValue(b *strings.Builder, v any, indent int, inList bool) {
+	pad := strings.Repeat("  ", indent)
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 {
+			b.WriteString("{}\n")
+			return
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			// The first key of a list item shares the line with "- ".
+			if i > 0 || !inList {
+				b.WriteString(pad)
+			}
+			b.WriteString(yamlString(k))
+			b.WriteString(":")
+			yamlChild(b, x[k], indent)
+		}
+	case []any:
+		if len(x) == 0 {
+			b.WriteString("[]\n")
+			return
+		}
+		for i, item := range x {
+			if i > 0 || !inList {
+				b.WriteString(pad)
+			}
+			b.WriteString("- ")
+			if isContainer(item) {
+				yamlValue(b, item, indent+1, true)
+			} else {
+				b.WriteString(yamlScalar(item))
+				b.WriteString("\n")
+			}
+		}
+	default:
+		b.WriteString(yamlScalar(x))
+		b.WriteString("\n")
+	}
+}
+
+func yamlChild(b *strings.Builder, v any, indent int) {
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		yamlValue(b, x, indent+1, false)
+	case []any:
+		if len(x) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		yamlValue(b, x, indent+1, false)
+	default:
+		b.WriteString(" ")
+		b.WriteString(yamlScalar(x))
+		b.WriteString("\n")
+	}
+}
+
+func isContainer(v any) bool {
+	switch x := v.(type) {
+	case map[string]any:
+		return len(x) > 0
+	case []any:
+		return len(x) > 0
+	}
+	return false
+}
+
+func yamlScalar(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(x)
+	case json.Number:
+		return x.String()
+	case string:
+		return yamlString(x)
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlString quotes s whenever a plain scalar could be misread, e.g. as a
+// number, boolean, null or a mapping.
+func yamlString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\n\t\\") || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?") ||
+		strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package picker
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Item is one selectable entry. Label is what the user sees and searches;
+// Value is returned when the item is chosen.
+type Item struct {
+	Label string
+	Value string
+}
+
+// match is an item that matched the current query, with its score and the
+// rune positions in Label that matched (for highlighting).
+type match struct {
+	item      Item
+	score     int
+	positions []int
+}
+
+// score reports whether every rune of query appears in label in order
+// (case-insensitively) and how good the match is. Consecutive runs and
+// matches at word starts score higher, and earlier matches beat later ones.
+func score(query, label string) (int, []int, bool) {
+	if query == "" {
+		return 0, nil, true
+	}
+	q := []rune(strings.ToLower(query))
+	l := []rune(label)
+
+	var positions []int
+	total := 0
+	qi := 0
+	prev := -2
+	for li := 0; li < len(l) && qi < len(q); li++ {
+		if unicode.ToLower(l[li]) != q[qi] {
+			continue
+		}
+		s := 1
+		if li == prev+1 {
+			s += 5
+		}
+		if li == 0 || !unicode.IsLetter(l[li-1]) && !unicode.IsDigit(l[li-1]) {
+			s += 3
+		}
+		total += s
+		positions = append(positions, li)
+		prev = li
+		qi++
+	}
+	if qi < len(q) {
+		return 0, nil, false
+	}
+	return total*100 - positions[0], positions, true
+}
+
+// filter returns the items matching query, best first. Ties keep the input
+// order so the caller's ordering (e.g. most recent first) is preserved.
+func filter(items []Item, query string) []match {
+	var matches []match
+	for _, it := range items {
+		if s, pos, ok := score(query, it.Label); ok {
+			matches = append(matches, match{item: it, score: s, positions: pos})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	return matches
+}
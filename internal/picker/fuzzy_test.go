@@ -0,0 +1,74 @@
+package picker
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/piotrwolkowski/tcli/internal/tty"
+)
+
+func TestFilter(t *testing.T) {
+	items := []Item{
+		{Label: "Project Alpha", Value: "1"},
+		{Label: "Alice, Bob", Value: "2"},
+		{Label: "Platform on-call", Value: "3"},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "empty query keeps order", query: "", want: []string{"1", "2", "3"}},
+		{name: "subsequence match", query: "pal", want: []string{"1", "3"}},
+		{name: "case insensitive", query: "BOB", want: []string{"2"}},
+		{name: "word starts rank first", query: "oc", want: []string{"3", "1"}},
+		{name: "no match", query: "zzz", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range filter(items, tt.query) {
+				got = append(got, m.item.Value)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("filter(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelHandle(t *testing.T) {
+	m := &model{items: []Item{{Label: "alpha", Value: "a"}, {Label: "beta", Value: "b"}}}
+	m.refilter()
+
+	keys := bufio.NewReader(strings.NewReader("x\x7f\x1b[B\r"))
+	for {
+		k, err := tty.ReadKey(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done, value, err := m.handle(k)
+		if !done {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("handle() error = %v", err)
+		}
+		if value != "b" {
+			t.Errorf("selected %q, want %q", value, "b")
+		}
+		return
+	}
+}
+
+func TestModelCancel(t *testing.T) {
+	m := &model{items: []Item{{Label: "alpha", Value: "a"}}}
+	m.refilter()
+	k, _ := tty.ReadKey(bufio.NewReader(strings.NewReader("\x03")))
+	if done, _, err := m.handle(k); !done || err != ErrCancelled {
+		t.Errorf("handle(Ctrl-C) = %v, %v; want done with ErrCancelled", done, err)
+	}
+}
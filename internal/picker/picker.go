@@ -0,0 +1,155 @@
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/piotrwolkowski/tcli/internal/tty"
+	"golang.org/x/term"
+)
+
+var (
+	// ErrCancelled is returned when the user dismisses the picker.
+	ErrCancelled = errors.New("selection cancelled")
+	// ErrNotTerminal is returned when stdin or stdout is not a terminal.
+	ErrNotTerminal = errors.New("interactive selection requires a terminal")
+)
+
+// Pick shows a full-screen fuzzy finder over items and returns the Value of
+// the chosen item. It returns ErrNotTerminal when in or out is not a TTY so
+// scripted callers fail instead of hanging.
+func Pick(in, out *os.File, prompt string, items []Item) (string, error) {
+	if !tty.IsTerminal(in, out) {
+		return "", ErrNotTerminal
+	}
+	if len(items) == 0 {
+		return "", fmt.Errorf("nothing to choose from")
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("entering raw mode: %w", err)
+	}
+	defer term.Restore(int(in.Fd()), state)
+
+	// Use the alternate screen so the picker leaves no trace when done.
+	fmt.Fprint(out, "\x1b[?1049h")
+	defer fmt.Fprint(out, "\x1b[?1049l")
+
+	p := &model{prompt: prompt, items: items}
+	p.refilter()
+
+	keys := bufio.NewReader(in)
+	for {
+		_, height, err := term.GetSize(int(out.Fd()))
+		if err != nil || height < 3 {
+			height = 24
+		}
+		p.render(out, height)
+
+		k, err := tty.ReadKey(keys)
+		if err != nil {
+			return "", err
+		}
+		if done, value, err := p.handle(k); done {
+			return value, err
+		}
+	}
+}
+
+type model struct {
+	prompt   string
+	items    []Item
+	query    []rune
+	matches  []match
+	selected int
+	offset   int
+}
+
+func (m *model) refilter() {
+	m.matches = filter(m.items, string(m.query))
+	m.selected = 0
+	m.offset = 0
+}
+
+// handle applies a keypress. It returns done once the user has chosen an
+// item or cancelled.
+func (m *model) handle(k tty.Key) (bool, string, error) {
+	switch k.Name {
+	case tty.KeyEnter:
+		if len(m.matches) == 0 {
+			return false, "", nil
+		}
+		return true, m.matches[m.selected].item.Value, nil
+	case tty.KeyEscape, tty.KeyInterrupt, tty.KeyEOF:
+		return true, "", ErrCancelled
+	case tty.KeyUp:
+		if m.selected > 0 {
+			m.selected--
+		}
+	case tty.KeyDown:
+		if m.selected < len(m.matches)-1 {
+			m.selected++
+		}
+	case tty.KeyBackspace:
+		if len(m.query) > 0 {
+			m.query = m.query[:len(m.query)-1]
+			m.refilter()
+		}
+	case tty.KeyClearLine:
+		m.query = nil
+		m.refilter()
+	case "":
+		if k.Rune >= ' ' {
+			m.query = append(m.query, k.Rune)
+			m.refilter()
+		}
+	}
+	return false, "", nil
+}
+
+func (m *model) render(w io.Writer, height int) {
+	rows := height - 2
+	if m.selected < m.offset {
+		m.offset = m.selected
+	}
+	if m.selected >= m.offset+rows {
+		m.offset = m.selected - rows + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	for i := m.offset; i < len(m.matches) && i < m.offset+rows; i++ {
+		line := highlight(m.matches[i])
+		if i == m.selected {
+			b.WriteString("\x1b[7m> " + line + "\x1b[0m\r\n")
+		} else {
+			b.WriteString("  " + line + "\r\n")
+		}
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2m  %d/%d\x1b[0m\r\n", height-1, len(m.matches), len(m.items))
+	fmt.Fprintf(&b, "%s%s", m.prompt, string(m.query))
+	io.WriteString(w, b.String())
+}
+
+// highlight underlines the matched runes of the label.
+func highlight(m match) string {
+	if len(m.positions) == 0 {
+		return m.item.Label
+	}
+	var b strings.Builder
+	next := 0
+	for i, r := range []rune(m.item.Label) {
+		if next < len(m.positions) && m.positions[next] == i {
+			b.WriteString("\x1b[4m" + string(r) + "\x1b[24m")
+			next++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
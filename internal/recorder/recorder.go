@@ -0,0 +1,244 @@
+// Package recorder captures Graph HTTP traffic to fixture files and serves it
+// back, so a problem can be reproduced, attached to a bug report or turned
+// into a regression test without credentials.
+//
+// Each request/response pair is one JSON file in the fixture directory,
+// numbered in the order the requests were made. Credentials are removed
+// with package redact before writing.
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/piotrwolkowski/tcli/internal/redact"
+)
+
+// Redacted replaces secrets in recorded fixtures.
+const Redacted = redact.Placeholder
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that passes requests to the next
+// transport and writes each exchange to a fixture file.
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder records into dir, which is created if needed. Requests are
+// sent with next, or http.DefaultTransport when next is nil.
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating record dir: %w", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	// Continue numbering after any fixtures already in dir.
+	existing, err := fixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, next: next, seq: len(existing)}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    redact.String(req.URL.String()),
+			Header: redact.Header(req.Header),
+			Body:   redact.String(string(reqBody)),
+		},
+		Response: RecordedResponse{
+			Status: resp.StatusCode,
+			Header: redact.Header(resp.Header),
+			Body:   redact.String(string(respBody)),
+		},
+	}
+	if err := r.write(in); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) write(in Interaction) error {
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	name := fmt.Sprintf("%04d-%s.json", r.seq, strings.ToLower(in.Request.Method))
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0600); err != nil {
+		return fmt.Errorf("writing fixture: %w", err)
+	}
+	return nil
+}
+
+// Replayer is an http.RoundTripper that answers requests from fixtures
+// instead of the network. A request is answered by the earliest unused
+// fixture with the same method and URL path and query, preferring one
+// with the same body, so replays are deterministic regardless of host.
+type Replayer struct {
+	mu      sync.Mutex
+	pending map[string][]Interaction
+}
+
+// NewReplayer loads the fixtures in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	names, err := fixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no recorded fixtures in %s", dir)
+	}
+	r := &Replayer{pending: map[string][]Interaction{}}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading fixture: %w", err)
+		}
+		var in Interaction
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, fmt.Errorf("parsing fixture %s: %w", name, err)
+		}
+		k, err := key(in.Request.Method, in.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", name, err)
+		}
+		r.pending[k] = append(r.pending[k], in)
+	}
+	return r, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	k, err := key(req.Method, redact.String(req.URL.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	queue := r.pending[k]
+	i := 0
+	for j, in := range queue {
+		if in.Request.Body == redact.String(string(body)) {
+			i = j
+			break
+		}
+	}
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("replay: no recorded response for %s", k)
+	}
+	in := queue[i]
+	r.pending[k] = append(queue[:i:i], queue[i+1:]...)
+	r.mu.Unlock()
+
+	header := in.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// Recorded throttling is replayed without the wait.
+	if header.Get("Retry-After") != "" {
+		header.Set("Retry-After", "0")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+		StatusCode:    in.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// key identifies a request by method and URL without scheme and host.
+func key(method, rawURL string) (string, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	return method + " " + req.URL.RequestURI(), nil
+}
+
+// fixtures lists the fixture files in dir in recording order.
+func fixtures(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixtures: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,38 @@
+// Package redact strips credentials from HTTP traffic before it is logged or
+// written to disk.
+package redact
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// Placeholder replaces each secret.
+const Placeholder = "REDACTED"
+
+// secretHeaders are replaced wholesale.
+var secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+var (
+	// jwtPattern matches JSON web tokens such as Entra access tokens.
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// secretFields matches token-bearing JSON fields and form/query values.
+	secretFields = regexp.MustCompile(`("(?:access_token|refresh_token|id_token|device_code|client_secret|client_assertion)"\s*:\s*")[^"]*(")|((?:access_token|refresh_token|id_token|device_code|client_secret|client_assertion|code)=)[^&\s"]*`)
+)
+
+// String removes tokens and other secrets from s.
+func String(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Placeholder)
+	return secretFields.ReplaceAllString(s, "${1}${3}"+Placeholder+"${2}")
+}
+
+// Header returns a copy of h with credential headers replaced.
+func Header(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range secretHeaders {
+		if len(out.Values(name)) > 0 {
+			out.Set(name, Placeholder)
+		}
+	}
+	return out
+}
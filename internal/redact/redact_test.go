@@ -0,0 +1,23 @@
+package redact
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "jwt", in: "token eyJhbGciOi.eyJzdWIiOi.c2lnbmF0dXJl here", want: "token REDACTED here"},
+		{name: "json token field", in: `{"refresh_token": "0.AXk-secret", "expires_in": 3600}`, want: `{"refresh_token": "REDACTED", "expires_in": 3600}`},
+		{name: "form values", in: "grant_type=device_code&device_code=DAQABAAEAAA&client_id=abc", want: "grant_type=device_code&device_code=REDACTED&client_id=abc"},
+		{name: "plain text untouched", in: `{"id":"19:abc@thread.v2"}`, want: `{"id":"19:abc@thread.v2"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
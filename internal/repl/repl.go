@@ -0,0 +1,264 @@
+// Package repl implements the line-oriented chat shell behind tcli chat open.
+// It reads and writes plain lines, so it works over SSH, inside script(1)
+// recordings and with piped input.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/markup"
+)
+
+// Backend is the subset of graph.Client the shell needs.
+type Backend interface {
+	ListMessages(ctx context.Context, chatID string, limit int) ([]graph.Message, error)
+	SendMessage(ctx context.Context, chatID, content string) (*graph.SendMessageResponse, error)
+	UpdateMessage(ctx context.Context, chatID, messageID, content string) error
+	SetReaction(ctx context.Context, chatID, messageID, reactionType string) error
+	SendFile(ctx context.Context, chatID, name string, content []byte, text string) (*graph.SendMessageResponse, error)
+}
+
+// Options configures the shell.
+type Options struct {
+	// PollInterval is how often the chat is checked for new messages.
+	PollInterval time.Duration
+	// History is how many recent messages are printed on start.
+	History int
+}
+
+type session struct {
+	backend Backend
+	chatID  string
+	out     io.Writer
+
+	seen     map[string]bool
+	lastSent string // ID of the last message sent from this session
+	latest   string // ID of the most recent message shown or sent
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, s *session, arg string) error
+}
+
+// errQuit ends the session cleanly.
+var errQuit = fmt.Errorf("quit")
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"/help":   {usage: "/help                 show this help", run: runHelp},
+		"/edit":   {usage: "/edit <text>          replace your last message", run: runEdit},
+		"/react":  {usage: "/react <reaction>     react to the latest message, e.g. /react ✅", run: runReact},
+		"/attach": {usage: "/attach <file>        send a file of up to 4 MB", run: runAttach},
+		"/quit": {usage: "/quit                 leave the chat (or Ctrl-D)", run: func(ctx context.Context, s *session, arg string) error {
+			return errQuit
+		}},
+	}
+}
+
+// Run prints recent history for chatID, then sends each line read from in as
+// a message while printing new incoming messages to out. Lines starting with
+// "/" are commands; "//" sends a literal leading slash.
+//
+// When Run returns it closes in if it is an io.Closer, so that a read
+// blocked on it can end.
+func Run(ctx context.Context, backend Backend, chatID string, in io.Reader, out io.Writer, opts Options) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.History <= 0 {
+		opts.History = 10
+	}
+
+	s := &session{backend: backend, chatID: chatID, out: out, seen: map[string]bool{}}
+	if err := s.poll(ctx, opts.History); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Type a message and press Enter to send. /help lists commands.")
+
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	if c, ok := in.(io.Closer); ok {
+		defer c.Close()
+	}
+	go func() {
+		sc := bufio.NewScanner(in)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-done:
+				return
+			}
+		}
+		readErr <- sc.Err()
+	}()
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case <-ticker.C:
+			if err := s.poll(ctx, opts.History); err != nil {
+				fmt.Fprintf(out, "! %v\n", err)
+			}
+		case line := <-lines:
+			err := s.handle(ctx, line)
+			if err == errQuit {
+				return nil
+			}
+			if err != nil {
+				fmt.Fprintf(out, "! %v\n", err)
+			}
+		}
+	}
+}
+
+func (s *session) handle(ctx context.Context, line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if strings.HasPrefix(line, "/") && !strings.HasPrefix(line, "//") {
+		name, arg, _ := strings.Cut(line, " ")
+		cmd, ok := commands[name]
+		if !ok {
+			return fmt.Errorf("unknown command %s — try /help", name)
+		}
+		return cmd.run(ctx, s, strings.TrimSpace(arg))
+	}
+	line = strings.TrimPrefix(line, "/")
+
+	resp, err := s.backend.SendMessage(ctx, s.chatID, line)
+	if err != nil {
+		return err
+	}
+	s.seen[resp.ID] = true
+	s.lastSent = resp.ID
+	s.latest = resp.ID
+	return nil
+}
+
+// poll prints messages not seen before, oldest first.
+func (s *session) poll(ctx context.Context, limit int) error {
+	msgs, err := s.backend.ListMessages(ctx, s.chatID, limit)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].CreatedAt < msgs[j].CreatedAt
+	})
+	for _, m := range msgs {
+		if s.seen[m.ID] {
+			continue
+		}
+		s.seen[m.ID] = true
+		if m.MessageType != "" && m.MessageType != "message" {
+			continue
+		}
+		printMessage(s.out, m)
+		s.latest = m.ID
+	}
+	return nil
+}
+
+func printMessage(w io.Writer, m graph.Message) {
+	text := markup.PlainText(m.Body.Content)
+	if m.DeletedAt != "" {
+		text = "(deleted)"
+	}
+	ts := m.CreatedAt
+	if t, err := time.Parse(time.RFC3339, m.CreatedAt); err == nil {
+		ts = t.Local().Format("15:04")
+	}
+	lines := strings.Split(text, "\n")
+	fmt.Fprintf(w, "[%s] %s: %s\n", ts, graph.SenderName(m), lines[0])
+	for _, l := range lines[1:] {
+		fmt.Fprintf(w, "    %s\n", l)
+	}
+	if r := graph.ReactionSummary(m); r != "" {
+		fmt.Fprintf(w, "    %s\n", r)
+	}
+}
+
+func runHelp(ctx context.Context, s *session, arg string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(s.out, "  "+commands[name].usage)
+	}
+	return nil
+}
+
+func runEdit(ctx context.Context, s *session, arg string) error {
+	if s.lastSent == "" {
+		return fmt.Errorf("nothing to edit — you haven't sent a message in this session")
+	}
+	if arg == "" {
+		return fmt.Errorf("usage: /edit <text>")
+	}
+	if err := s.backend.UpdateMessage(ctx, s.chatID, s.lastSent, arg); err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, "(edited)")
+	return nil
+}
+
+func runAttach(ctx context.Context, s *session, arg string) error {
+	if arg == "" {
+		return fmt.Errorf("usage: /attach <file>")
+	}
+	info, err := os.Stat(arg)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", arg)
+	}
+	if info.Size() > graph.MaxUploadSize {
+		return fmt.Errorf("%s is too large — files over %d MB cannot be attached", arg, graph.MaxUploadSize>>20)
+	}
+	content, err := os.ReadFile(arg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.backend.SendFile(ctx, s.chatID, filepath.Base(arg), content, "")
+	if err != nil {
+		return err
+	}
+	// Not made lastSent: /edit replaces the body, which holds the attachment.
+	s.seen[resp.ID] = true
+	s.latest = resp.ID
+	fmt.Fprintf(s.out, "(sent %s)\n", filepath.Base(arg))
+	return nil
+}
+
+func runReact(ctx context.Context, s *session, arg string) error {
+	if arg == "" {
+		return fmt.Errorf("usage: /react <reaction>")
+	}
+	if s.latest == "" {
+		return fmt.Errorf("no message to react to yet")
+	}
+	return s.backend.SetReaction(ctx, s.chatID, s.latest, arg)
+}
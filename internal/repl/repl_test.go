@@ -0,0 +1,131 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+)
+
+type fakeBackend struct {
+	messages  []graph.Message
+	sent      []string
+	edits     []string
+	reactions []string
+	files     []string
+}
+
+func (f *fakeBackend) ListMessages(ctx context.Context, chatID string, limit int) ([]graph.Message, error) {
+	return f.messages, nil
+}
+
+func (f *fakeBackend) SendMessage(ctx context.Context, chatID, content string) (*graph.SendMessageResponse, error) {
+	f.sent = append(f.sent, content)
+	return &graph.SendMessageResponse{ID: fmt.Sprintf("sent-%d", len(f.sent))}, nil
+}
+
+func (f *fakeBackend) UpdateMessage(ctx context.Context, chatID, messageID, content string) error {
+	f.edits = append(f.edits, messageID+"="+content)
+	return nil
+}
+
+func (f *fakeBackend) SetReaction(ctx context.Context, chatID, messageID, reactionType string) error {
+	f.reactions = append(f.reactions, messageID+"="+reactionType)
+	return nil
+}
+
+func (f *fakeBackend) SendFile(ctx context.Context, chatID, name string, content []byte, text string) (*graph.SendMessageResponse, error) {
+	f.files = append(f.files, name+"="+string(content))
+	return &graph.SendMessageResponse{ID: fmt.Sprintf("file-%d", len(f.files))}, nil
+}
+
+func user(name string) *graph.MessageFrom {
+	return &graph.MessageFrom{User: &graph.Identity{DisplayName: name}}
+}
+
+func TestRun(t *testing.T) {
+	backend := &fakeBackend{messages: []graph.Message{
+		{ID: "2", MessageType: "message", CreatedAt: "2026-01-01T10:01:00Z", From: user("Bob"), Body: graph.MessageBody{Content: "<p>second</p>"}},
+		{ID: "1", MessageType: "message", CreatedAt: "2026-01-01T10:00:00Z", From: user("Alice"), Body: graph.MessageBody{Content: "first"}},
+	}}
+	in := strings.NewReader("/react 👍\nhello\n//slash\n/edit fixed\n/bogus\n/quit\nnot sent\n")
+	var out bytes.Buffer
+
+	if err := Run(context.Background(), backend, "chat", in, &out, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	got := out.String()
+	if i, j := strings.Index(got, "Alice: first"), strings.Index(got, "Bob: second"); i < 0 || j < 0 || i > j {
+		t.Errorf("history not printed oldest first:\n%s", got)
+	}
+	if strings.Join(backend.sent, "|") != "hello|/slash" {
+		t.Errorf("sent = %q, want [hello /slash]", backend.sent)
+	}
+	if len(backend.edits) != 1 || backend.edits[0] != "sent-2=fixed" {
+		t.Errorf("edits = %q, want [sent-2=fixed]", backend.edits)
+	}
+	if len(backend.reactions) != 1 || backend.reactions[0] != "2=👍" {
+		t.Errorf("reactions = %q, want [2=👍] (the latest message)", backend.reactions)
+	}
+	if !strings.Contains(got, "unknown command /bogus") {
+		t.Errorf("expected unknown command error in output:\n%s", got)
+	}
+}
+
+func TestPollSkipsSeen(t *testing.T) {
+	backend := &fakeBackend{messages: []graph.Message{
+		{ID: "1", MessageType: "message", From: user("Alice"), Body: graph.MessageBody{Content: "hi"}},
+	}}
+	var out bytes.Buffer
+	s := &session{backend: backend, out: &out, seen: map[string]bool{}}
+
+	s.poll(context.Background(), 10)
+	s.poll(context.Background(), 10)
+	if n := strings.Count(out.String(), "Alice: hi"); n != 1 {
+		t.Errorf("message printed %d times, want 1", n)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	os.WriteFile(file, []byte("hello"), 0600)
+	big := filepath.Join(dir, "big.bin")
+	os.WriteFile(big, make([]byte, graph.MaxUploadSize+1), 0600)
+
+	backend := &fakeBackend{}
+	in := strings.NewReader("/attach " + file + "\n/attach " + big + "\n/attach\n")
+	var out bytes.Buffer
+	if err := Run(context.Background(), backend, "chat", in, &out, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(backend.files) != 1 || backend.files[0] != "notes.txt=hello" {
+		t.Errorf("files = %q, want [notes.txt=hello]", backend.files)
+	}
+	got := out.String()
+	if !strings.Contains(got, "(sent notes.txt)") || !strings.Contains(got, "too large") || !strings.Contains(got, "usage: /attach") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
+
+func TestRunClosesInput(t *testing.T) {
+	pr, pw := io.Pipe()
+	go io.WriteString(pw, "/quit\n")
+
+	if err := Run(context.Background(), &fakeBackend{}, "chat", pr, io.Discard, Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// The reader goroutine is unblocked once the input is closed.
+	if _, err := io.WriteString(pw, "more\n"); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("write after /quit = %v, want closed pipe", err)
+	}
+}
@@ -0,0 +1,387 @@
+// Package spool keeps local queues of messages waiting to be sent: messages
+// scheduled for later and messages whose delivery failed. Each queued
+// message is its own JSON file, so adding, removing and delivering never
+// rewrite a shared file and can safely run concurrently.
+package spool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/dedupe"
+	"github.com/piotrwolkowski/tcli/internal/graph"
+)
+
+const (
+	pendingExt = ".json"
+	// claimedExt marks an item a runner is currently delivering.
+	claimedExt = ".sending"
+)
+
+// ClaimTimeout is how long an item may stay claimed before RunDue assumes
+// its runner died and puts it back in the queue. Delivering one message
+// takes far less, even with retries.
+const ClaimTimeout = 10 * time.Minute
+
+// Item is a queued message.
+type Item struct {
+	ID        string                   `json:"id"`
+	ChatID    string                   `json:"chatId"`
+	Message   graph.SendMessageRequest `json:"message"`
+	DueAt     time.Time                `json:"dueAt"`
+	CreatedAt time.Time                `json:"createdAt"`
+	Attempts  int                      `json:"attempts,omitempty"`
+	LastError string                   `json:"lastError,omitempty"`
+	// NextAttempt delays the next delivery attempt after a transient failure.
+	NextAttempt time.Time `json:"nextAttempt,omitzero"`
+	// Failed is set when delivery failed permanently (e.g. the chat does not
+	// exist); such items are kept for inspection but never retried.
+	Failed bool `json:"failed,omitempty"`
+	// DedupeKey, when set, is the idempotency key the message was sent
+	// with. The message is not delivered if the key was used for the chat
+	// within DedupeWindow (zero meaning dedupe.Retention), and a delivery is
+	// recorded under the key.
+	DedupeKey    string        `json:"dedupeKey,omitempty"`
+	DedupeWindow time.Duration `json:"dedupeWindow,omitempty"`
+	// Sending is set on items a runner has claimed but not finished.
+	Sending bool `json:"sending,omitempty"`
+}
+
+// ReadyAt is when the item may next be delivered.
+func (i Item) ReadyAt() time.Time {
+	if i.NextAttempt.After(i.DueAt) {
+		return i.NextAttempt
+	}
+	return i.DueAt
+}
+
+// Backoff returns how long to wait before retrying after the given number of
+// failed attempts: 30s, 1m, 2m, … capped at one hour.
+func Backoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// Sender delivers messages; *graph.Client satisfies it.
+type Sender interface {
+	PostMessage(ctx context.Context, chatID string, payload graph.SendMessageRequest) (*graph.SendMessageResponse, error)
+}
+
+// Queue is a directory of queued messages.
+type Queue struct {
+	dir string
+	// Sent, when set, is checked and updated for items with a DedupeKey.
+	Sent *dedupe.Store
+}
+
+// NewQueue returns the queue stored in dir. The directory is created on first use.
+func NewQueue(dir string) *Queue {
+	return &Queue{dir: dir}
+}
+
+// Add stores a new item and returns it with its ID and creation time
+// assigned. A zero DueAt means the item is due immediately.
+func (q *Queue) Add(item Item) (*Item, error) {
+	if err := os.MkdirAll(q.dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating queue dir: %w", err)
+	}
+	now := time.Now().UTC()
+	if item.DueAt.IsZero() {
+		item.DueAt = now
+	}
+	item.DueAt = item.DueAt.UTC()
+	item.ID = newID(item.DueAt)
+	item.CreatedAt = now
+	if err := q.write(&item, pendingExt); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// List returns all queued messages, soonest first.
+func (q *Queue) List() ([]Item, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading queue: %w", err)
+	}
+
+	var items []Item
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if ext != pendingExt && ext != claimedExt {
+			continue
+		}
+		item, err := q.read(e.Name())
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // delivered or cancelled while listing
+			}
+			return nil, err
+		}
+		item.Sending = ext == claimedExt
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ReadyAt().Before(items[j].ReadyAt())
+	})
+	return items, nil
+}
+
+// Remove deletes a queued message. A unique prefix of the ID is enough.
+func (q *Queue) Remove(id string) (*Item, error) {
+	items, err := q.List()
+	if err != nil {
+		return nil, err
+	}
+	var match *Item
+	for i := range items {
+		if items[i].ID == id {
+			match = &items[i]
+			break
+		}
+		if strings.HasPrefix(items[i].ID, id) {
+			if match != nil {
+				return nil, fmt.Errorf("%q matches more than one queued message", id)
+			}
+			match = &items[i]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no queued message %q", id)
+	}
+	ext := pendingExt
+	if match.Sending {
+		ext = claimedExt
+	}
+	if err := os.Remove(q.path(match.ID, ext)); err != nil {
+		return nil, fmt.Errorf("removing %s: %w", match.ID, err)
+	}
+	return match, nil
+}
+
+// Result reports the outcome of delivering one queued message.
+type Result struct {
+	Item      Item
+	MessageID string
+	// Duplicate is set when the item was dropped because its DedupeKey had
+	// already been used; MessageID is then the earlier message.
+	Duplicate bool
+	Err       error
+	// RecordErr is set when the message was sent but could not be recorded
+	// under its DedupeKey.
+	RecordErr error
+}
+
+// RunDue delivers every message ready at or before now. Each item is claimed
+// by renaming its file before sending, so concurrent runners never deliver
+// the same message twice. An item with a DedupeKey is looked up, sent and
+// recorded under the key's lock (see dedupe.Store.Lock), so a tcli send with
+// the same key cannot post it too. After a transient failure the item goes
+// back in the queue and is retried with Backoff; after a permanent failure it
+// is marked Failed and kept until removed.
+//
+// When ctx is cancelled, or the sender cannot send as a user at all
+// (graph.ErrUserRequired), the item being sent goes back in the queue
+// unchanged and RunDue returns the results so far with the error. Items
+// left claimed for longer than ClaimTimeout, e.g. by a runner that was
+// killed, are queued again first; such an item may be delivered twice if
+// its runner died after sending it.
+func (q *Queue) RunDue(ctx context.Context, sender Sender, now time.Time) ([]Result, error) {
+	q.recoverStale(time.Now().Add(-ClaimTimeout))
+	items, err := q.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, item := range items {
+		if item.Sending || item.Failed || item.ReadyAt().After(now) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if err := q.claim(item.ID); err != nil {
+			continue // claimed or cancelled by someone else
+		}
+
+		release, err := q.lockSent(ctx, item)
+		if err != nil {
+			q.unclaim(item.ID)
+			return results, err
+		}
+		if prev := q.lookupSent(item); prev != nil {
+			release()
+			os.Remove(q.path(item.ID, claimedExt))
+			results = append(results, Result{Item: item, MessageID: prev.MessageID, Duplicate: true})
+			continue
+		}
+
+		resp, err := sender.PostMessage(ctx, item.ChatID, item.Message)
+		if err != nil {
+			release()
+		}
+		if err != nil && ctx.Err() != nil {
+			q.unclaim(item.ID)
+			return results, ctx.Err()
+		}
+		if errors.Is(err, graph.ErrUserRequired) {
+			q.unclaim(item.ID)
+			return results, err
+		}
+		if err != nil {
+			item.Attempts++
+			item.LastError = err.Error()
+			if graph.IsTransient(err) {
+				item.NextAttempt = now.Add(Backoff(item.Attempts)).UTC()
+			} else {
+				item.Failed = true
+			}
+			if werr := q.write(&item, pendingExt); werr == nil {
+				os.Remove(q.path(item.ID, claimedExt))
+			} else {
+				q.unclaim(item.ID) // retried without the failure recorded
+			}
+			results = append(results, Result{Item: item, Err: err})
+			continue
+		}
+		os.Remove(q.path(item.ID, claimedExt))
+		result := Result{Item: item, MessageID: resp.ID}
+		if q.Sent != nil && item.DedupeKey != "" {
+			result.RecordErr = q.Sent.Save(dedupe.Record{
+				Key:         item.DedupeKey,
+				ChatID:      item.ChatID,
+				MessageID:   resp.ID,
+				CreatedAt:   resp.CreatedAt,
+				ContentHash: dedupe.Hash(item.Message.Body.Content),
+			})
+		}
+		release()
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// lockSent locks item's DedupeKey until release is called, so that a tcli
+// send with the same key waits for the delivery to be recorded. Only a
+// cancelled ctx is an error; a lock that cannot be taken otherwise does not
+// hold up delivery, as with lookupSent.
+func (q *Queue) lockSent(ctx context.Context, item Item) (release func(), err error) {
+	if q.Sent == nil || item.DedupeKey == "" {
+		return func() {}, nil
+	}
+	release, err = q.Sent.Lock(ctx, item.ChatID, item.DedupeKey)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return func() {}, nil
+	}
+	return release, nil
+}
+
+// lookupSent returns the earlier message sent under item's DedupeKey, if
+// any. A failed lookup does not hold up delivery, as in tcli send.
+func (q *Queue) lookupSent(item Item) *dedupe.Record {
+	if q.Sent == nil || item.DedupeKey == "" {
+		return nil
+	}
+	prev, _ := q.Sent.Lookup(item.ChatID, item.DedupeKey, item.DedupeWindow)
+	return prev
+}
+
+// claim marks an item as being delivered. The claim's modification time
+// records when it was taken.
+func (q *Queue) claim(id string) error {
+	claimed := q.path(id, claimedExt)
+	if err := os.Rename(q.path(id, pendingExt), claimed); err != nil {
+		return err
+	}
+	now := time.Now()
+	os.Chtimes(claimed, now, now)
+	return nil
+}
+
+// unclaim returns a claimed item to the queue as it was.
+func (q *Queue) unclaim(id string) error {
+	return os.Rename(q.path(id, claimedExt), q.path(id, pendingExt))
+}
+
+// recoverStale returns items claimed before cutoff to the queue.
+func (q *Queue) recoverStale(cutoff time.Time) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), claimedExt)
+		if !ok {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.ModTime().Before(cutoff) {
+			q.unclaim(id)
+		}
+	}
+}
+
+func (q *Queue) path(id, ext string) string {
+	return filepath.Join(q.dir, id+ext)
+}
+
+func (q *Queue) read(name string) (*Item, error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("parsing queued message %s: %w", name, err)
+	}
+	return &item, nil
+}
+
+// write stores item atomically: readers see either the old or the new file.
+func (q *Queue) write(item *Item, ext string) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(q.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("writing queued message: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing queued message: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing queued message: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), q.path(item.ID, ext)); err != nil {
+		return fmt.Errorf("writing queued message: %w", err)
+	}
+	return nil
+}
+
+// newID returns a sortable, unique ID: the due time followed by random bytes.
+func newID(due time.Time) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return due.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
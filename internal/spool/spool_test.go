@@ -0,0 +1,280 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/dedupe"
+	"github.com/piotrwolkowski/tcli/internal/graph"
+)
+
+type fakeSender struct {
+	sent []string
+	err  error
+}
+
+func (f *fakeSender) PostMessage(ctx context.Context, chatID string, payload graph.SendMessageRequest) (*graph.SendMessageResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.sent = append(f.sent, chatID+":"+payload.Body.Content)
+	return &graph.SendMessageResponse{ID: "m1"}, nil
+}
+
+func msg(content string) graph.SendMessageRequest {
+	return graph.SendMessageRequest{Body: graph.MessageBody{Content: content}}
+}
+
+func add(t *testing.T, q *Queue, content string, due time.Time) *Item {
+	t.Helper()
+	item, err := q.Add(Item{ChatID: "chat", Message: msg(content), DueAt: due})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestAddListRemove(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	now := time.Now()
+
+	later := add(t, q, "later", now.Add(2*time.Hour))
+	add(t, q, "sooner", now.Add(time.Hour))
+
+	items, err := q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Message.Body.Content != "sooner" {
+		t.Fatalf("List() = %+v, want 2 items soonest first", items)
+	}
+
+	if _, err := q.Remove(later.ID[:len(later.ID)-2]); err != nil {
+		t.Fatalf("Remove by prefix: %v", err)
+	}
+	if _, err := q.Remove("nope"); err == nil {
+		t.Error("Remove of unknown ID succeeded")
+	}
+	items, _ = q.List()
+	if len(items) != 1 {
+		t.Errorf("expected 1 item after remove, got %d", len(items))
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	now := time.Now()
+	add(t, q, "due", now.Add(-time.Minute))
+	add(t, q, "not yet", now.Add(time.Hour))
+
+	sender := &fakeSender{}
+	results, err := q.RunDue(context.Background(), sender, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err != nil || results[0].MessageID != "m1" {
+		t.Fatalf("RunDue() = %+v, want one successful delivery", results)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "chat:due" {
+		t.Errorf("sent = %v", sender.sent)
+	}
+
+	items, _ := q.List()
+	if len(items) != 1 || items[0].Message.Body.Content != "not yet" {
+		t.Errorf("remaining = %+v, want only the future message", items)
+	}
+}
+
+func TestRunDueTransientFailureBacksOff(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	now := time.Now()
+	add(t, q, "due", now.Add(-time.Minute))
+
+	down := &fakeSender{err: graph.TransientError{Err: errors.New("network down")}}
+	results, err := q.RunDue(context.Background(), down, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("RunDue() = %+v, want one failure", results)
+	}
+
+	items, _ := q.List()
+	if len(items) != 1 || items[0].Attempts != 1 || items[0].LastError != "network down" || items[0].Sending || items[0].Failed {
+		t.Fatalf("requeued item = %+v", items)
+	}
+	if !items[0].NextAttempt.Equal(now.Add(Backoff(1)).UTC()) {
+		t.Errorf("NextAttempt = %v, want now + %v", items[0].NextAttempt, Backoff(1))
+	}
+
+	// Not retried before the backoff expires.
+	sender := &fakeSender{}
+	q.RunDue(context.Background(), sender, now.Add(time.Second))
+	if len(sender.sent) != 0 {
+		t.Fatalf("retried during backoff: sent = %v", sender.sent)
+	}
+	q.RunDue(context.Background(), sender, now.Add(Backoff(1)))
+	if len(sender.sent) != 1 {
+		t.Errorf("retry after backoff did not deliver: sent = %v", sender.sent)
+	}
+}
+
+func TestRunDuePermanentFailureIsKept(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	now := time.Now()
+	add(t, q, "due", now.Add(-time.Minute))
+
+	q.RunDue(context.Background(), &fakeSender{err: errors.New("chat not found")}, now)
+
+	items, _ := q.List()
+	if len(items) != 1 || !items[0].Failed {
+		t.Fatalf("items = %+v, want one failed item", items)
+	}
+	sender := &fakeSender{}
+	q.RunDue(context.Background(), sender, now.Add(24*time.Hour))
+	if len(sender.sent) != 0 {
+		t.Errorf("permanently failed item was retried")
+	}
+}
+
+// cancellingSender cancels the run while sending, as Ctrl-C would.
+type cancellingSender struct {
+	cancel context.CancelFunc
+	calls  int
+}
+
+func (c *cancellingSender) PostMessage(ctx context.Context, chatID string, payload graph.SendMessageRequest) (*graph.SendMessageResponse, error) {
+	c.calls++
+	c.cancel()
+	return nil, ctx.Err()
+}
+
+func TestRunDueCancelledKeepsItemsQueued(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	now := time.Now()
+	add(t, q, "first", now.Add(-2*time.Minute))
+	add(t, q, "second", now.Add(-time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := &cancellingSender{cancel: cancel}
+	results, err := q.RunDue(ctx, sender, now)
+	if !errors.Is(err, context.Canceled) || len(results) != 0 {
+		t.Fatalf("RunDue() = %+v, %v; want no results and context.Canceled", results, err)
+	}
+	if sender.calls != 1 {
+		t.Errorf("sent %d messages after cancellation, want 1 attempt", sender.calls)
+	}
+
+	items, _ := q.List()
+	if len(items) != 2 {
+		t.Fatalf("items = %+v, want both still queued", items)
+	}
+	for _, item := range items {
+		if item.Failed || item.Sending || item.Attempts != 0 || item.LastError != "" {
+			t.Errorf("item after cancellation = %+v, want it untouched", item)
+		}
+	}
+}
+
+func TestRunDueWithoutUserKeepsItemsQueued(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	now := time.Now()
+	add(t, q, "first", now.Add(-2*time.Minute))
+	add(t, q, "second", now.Add(-time.Minute))
+
+	appOnly := &fakeSender{err: fmt.Errorf("sending messages: %w", graph.ErrUserRequired)}
+	results, err := q.RunDue(context.Background(), appOnly, now)
+	if !errors.Is(err, graph.ErrUserRequired) || len(results) != 0 {
+		t.Fatalf("RunDue() = %+v, %v; want no results and ErrUserRequired", results, err)
+	}
+
+	items, _ := q.List()
+	if len(items) != 2 {
+		t.Fatalf("items = %+v, want both still queued", items)
+	}
+	for _, item := range items {
+		if item.Failed || item.Sending || item.Attempts != 0 || item.LastError != "" {
+			t.Errorf("item after app-only run = %+v, want it untouched", item)
+		}
+	}
+}
+
+func TestRunDueRecoversStaleClaims(t *testing.T) {
+	dir := t.TempDir()
+	q := NewQueue(dir)
+	now := time.Now()
+	stale := add(t, q, "stale", now.Add(-time.Hour))
+	fresh := add(t, q, "fresh", now.Add(-time.Hour))
+	for _, id := range []string{stale.ID, fresh.ID} {
+		if err := q.claim(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := now.Add(-ClaimTimeout - time.Minute)
+	os.Chtimes(filepath.Join(dir, stale.ID+claimedExt), old, old)
+
+	sender := &fakeSender{}
+	if _, err := q.RunDue(context.Background(), sender, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "chat:stale" {
+		t.Errorf("sent = %v, want only the stale claim redelivered", sender.sent)
+	}
+	items, _ := q.List()
+	if len(items) != 1 || items[0].ID != fresh.ID || !items[0].Sending {
+		t.Errorf("items = %+v, want the fresh claim left alone", items)
+	}
+}
+
+func TestRunDueRecordsDedupeKey(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	q.Sent = dedupe.NewStore(t.TempDir())
+	now := time.Now()
+	q.Add(Item{ChatID: "chat", Message: msg("deployed"), DueAt: now.Add(-time.Minute), DedupeKey: "deploy-1"})
+
+	sender := &fakeSender{}
+	results, err := q.RunDue(context.Background(), sender, now)
+	if err != nil || len(results) != 1 || results[0].RecordErr != nil {
+		t.Fatalf("RunDue() = %+v, %v", results, err)
+	}
+	rec, err := q.Sent.Lookup("chat", "deploy-1", 0)
+	if err != nil || rec == nil || rec.MessageID != "m1" || rec.Changed("deployed") {
+		t.Fatalf("sent record = %+v, %v; want message m1 recorded under the key", rec, err)
+	}
+
+	// Queued again, e.g. by a second failed send with the same key.
+	q.Add(Item{ChatID: "chat", Message: msg("deployed"), DueAt: now.Add(-time.Minute), DedupeKey: "deploy-1"})
+	results, err = q.RunDue(context.Background(), sender, now)
+	if err != nil || len(results) != 1 || !results[0].Duplicate || results[0].MessageID != "m1" {
+		t.Fatalf("RunDue() of a duplicate = %+v, %v; want it dropped", results, err)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("sent = %v, want one delivery", sender.sent)
+	}
+	if items, _ := q.List(); len(items) != 0 {
+		t.Errorf("items = %+v, want the duplicate removed", items)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
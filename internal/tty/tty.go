@@ -0,0 +1,99 @@
+// Package tty provides the small amount of terminal handling shared by the
+// interactive commands: TTY detection and keypress decoding.
+package tty
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Names of the non-printable keys reported by ReadKey.
+const (
+	KeyUp        = "up"
+	KeyDown      = "down"
+	KeyPageUp    = "pgup"
+	KeyPageDown  = "pgdn"
+	KeyHome      = "home"
+	KeyEnd       = "end"
+	KeyEnter     = "enter"
+	KeyTab       = "tab"
+	KeyBackspace = "backspace"
+	KeyEscape    = "esc"
+	KeyClearLine = "ctrl-u"
+	KeyInterrupt = "ctrl-c"
+	KeyEOF       = "ctrl-d"
+)
+
+// Key is one decoded keypress: either a printable Rune or a named key.
+type Key struct {
+	Rune rune
+	Name string
+}
+
+// IsTerminal reports whether both in and out are attached to a terminal.
+func IsTerminal(in, out *os.File) bool {
+	return term.IsTerminal(int(in.Fd())) && term.IsTerminal(int(out.Fd()))
+}
+
+// ReadKey decodes one keypress from a terminal in raw mode, including the
+// common VT100/xterm escape sequences. End of input is reported as KeyEOF.
+func ReadKey(r *bufio.Reader) (Key, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return Key{Name: KeyEOF}, nil
+		}
+		return Key{}, err
+	}
+	switch c {
+	case '\r', '\n':
+		return Key{Name: KeyEnter}, nil
+	case '\t':
+		return Key{Name: KeyTab}, nil
+	case 127, 8:
+		return Key{Name: KeyBackspace}, nil
+	case 3:
+		return Key{Name: KeyInterrupt}, nil
+	case 4:
+		return Key{Name: KeyEOF}, nil
+	case 14: // Ctrl-N
+		return Key{Name: KeyDown}, nil
+	case 16: // Ctrl-P
+		return Key{Name: KeyUp}, nil
+	case 21:
+		return Key{Name: KeyClearLine}, nil
+	case 0x1b:
+		// A lone escape arrives on its own; sequences arrive in one read.
+		if r.Buffered() == 0 {
+			return Key{Name: KeyEscape}, nil
+		}
+		seq := make([]byte, 0, 4)
+		for r.Buffered() > 0 {
+			b, _ := r.ReadByte()
+			seq = append(seq, b)
+			if len(seq) > 1 && (b >= 'A' && b <= 'Z' || b == '~') {
+				break
+			}
+		}
+		switch string(seq) {
+		case "[A", "OA":
+			return Key{Name: KeyUp}, nil
+		case "[B", "OB":
+			return Key{Name: KeyDown}, nil
+		case "[5~":
+			return Key{Name: KeyPageUp}, nil
+		case "[6~":
+			return Key{Name: KeyPageDown}, nil
+		case "[H", "OH", "[1~":
+			return Key{Name: KeyHome}, nil
+		case "[F", "OF", "[4~":
+			return Key{Name: KeyEnd}, nil
+		}
+		return Key{}, nil
+	}
+	return Key{Rune: c}, nil
+}
@@ -0,0 +1,42 @@
+package tty
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\r\x1b[A\x1b[B\x1b[5~\x1b[6~\x7f\x03é"))
+	want := []Key{
+		{Rune: 'a'},
+		{Name: KeyEnter},
+		{Name: KeyUp},
+		{Name: KeyDown},
+		{Name: KeyPageUp},
+		{Name: KeyPageDown},
+		{Name: KeyBackspace},
+		{Name: KeyInterrupt},
+		{Rune: 'é'},
+		{Name: KeyEOF},
+	}
+	for i, w := range want {
+		got, err := ReadKey(r)
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if got != w {
+			t.Errorf("key %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestReadKeyLoneEscape(t *testing.T) {
+	got, err := ReadKey(bufio.NewReader(strings.NewReader("\x1b")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != KeyEscape {
+		t.Errorf("ReadKey() = %+v, want escape", got)
+	}
+}
@@ -0,0 +1,464 @@
+// Package tui implements the full-screen terminal interface behind tcli ui.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/markup"
+	"github.com/piotrwolkowski/tcli/internal/tty"
+	"golang.org/x/term"
+)
+
+// Backend is the subset of graph.Client the UI needs.
+type Backend interface {
+	ListChats(ctx context.Context) ([]graph.Chat, error)
+	ListMessages(ctx context.Context, chatID string, limit int) ([]graph.Message, error)
+	SendMessage(ctx context.Context, chatID, content string) (*graph.SendMessageResponse, error)
+}
+
+// Options configures the UI.
+type Options struct {
+	// PollInterval is how often the open chat is checked for new messages.
+	PollInterval time.Duration
+	// History is how many recent messages are loaded per chat.
+	History int
+}
+
+type focus int
+
+const (
+	focusChats focus = iota
+	focusCompose
+)
+
+// Events delivered to the main loop. All state is owned by the loop
+// goroutine; background work only ever communicates through these.
+type (
+	keyEvent   tty.Key
+	chatsEvent struct {
+		chats []graph.Chat
+		err   error
+	}
+	messagesEvent struct {
+		chatID   string
+		messages []graph.Message
+		err      error
+	}
+	sentEvent struct {
+		chatID string
+		err    error
+	}
+	tickEvent struct{}
+)
+
+type app struct {
+	backend Backend
+	opts    Options
+
+	chats    []graph.Chat
+	selected int
+	chatTop  int
+
+	openChat string
+	messages []graph.Message
+	scroll   int // lines scrolled up from the bottom of the history
+
+	compose []rune
+	focus   focus
+	status  string
+
+	width, height int
+}
+
+// Run starts the UI on the given terminal and blocks until the user quits.
+func Run(ctx context.Context, backend Backend, in, out *os.File, opts Options) error {
+	if !tty.IsTerminal(in, out) {
+		return fmt.Errorf("tcli ui requires an interactive terminal")
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.History <= 0 {
+		opts.History = 50
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return fmt.Errorf("entering raw mode: %w", err)
+	}
+	defer term.Restore(int(in.Fd()), state)
+
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan any, 16)
+	go readKeys(ctx, in, events)
+	go tick(ctx, events)
+
+	a := &app{backend: backend, opts: opts, status: "Loading chats…"}
+	go a.loadChats(ctx, events)
+
+	lastPoll := time.Now()
+	for {
+		w, h, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			w, h = 80, 24
+		}
+		a.width, a.height = w, h
+		fmt.Fprint(out, a.render())
+
+		var ev any
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev = <-events:
+		}
+
+		if _, ok := ev.(tickEvent); ok && a.openChat != "" && time.Since(lastPoll) >= opts.PollInterval {
+			lastPoll = time.Now()
+			go a.loadMessages(ctx, a.openChat, events)
+		}
+		if quit := a.update(ctx, ev, events); quit {
+			return nil
+		}
+	}
+}
+
+func readKeys(ctx context.Context, in *os.File, events chan<- any) {
+	r := bufio.NewReader(in)
+	for {
+		k, err := tty.ReadKey(r)
+		if err != nil {
+			return
+		}
+		select {
+		case events <- keyEvent(k):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func tick(ctx context.Context, events chan<- any) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			select {
+			case events <- tickEvent{}:
+			default:
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// emit delivers the result of background work unless the UI has exited.
+func emit(ctx context.Context, events chan<- any, ev any) {
+	select {
+	case events <- ev:
+	case <-ctx.Done():
+	}
+}
+
+func (a *app) loadChats(ctx context.Context, events chan<- any) {
+	chats, err := a.backend.ListChats(ctx)
+	emit(ctx, events, chatsEvent{chats: chats, err: err})
+}
+
+func (a *app) loadMessages(ctx context.Context, chatID string, events chan<- any) {
+	msgs, err := a.backend.ListMessages(ctx, chatID, a.opts.History)
+	emit(ctx, events, messagesEvent{chatID: chatID, messages: msgs, err: err})
+}
+
+func (a *app) send(ctx context.Context, chatID, content string, events chan<- any) {
+	_, err := a.backend.SendMessage(ctx, chatID, content)
+	emit(ctx, events, sentEvent{chatID: chatID, err: err})
+}
+
+// update applies one event to the state and reports whether to quit.
+func (a *app) update(ctx context.Context, ev any, events chan<- any) bool {
+	switch e := ev.(type) {
+	case chatsEvent:
+		if e.err != nil {
+			a.status = "Error: " + e.err.Error()
+			return false
+		}
+		sort.SliceStable(e.chats, func(i, j int) bool {
+			return e.chats[i].LastUpdated > e.chats[j].LastUpdated
+		})
+		a.chats = e.chats
+		if a.selected >= len(a.chats) {
+			a.selected = max(len(a.chats)-1, 0)
+		}
+		a.status = fmt.Sprintf("%d chats", len(a.chats))
+	case messagesEvent:
+		if e.chatID != a.openChat {
+			return false // stale response for a chat we've left
+		}
+		if e.err != nil {
+			a.status = "Error: " + e.err.Error()
+			return false
+		}
+		// Graph returns newest first; display oldest first.
+		msgs := make([]graph.Message, 0, len(e.messages))
+		for i := len(e.messages) - 1; i >= 0; i-- {
+			if e.messages[i].MessageType == "" || e.messages[i].MessageType == "message" {
+				msgs = append(msgs, e.messages[i])
+			}
+		}
+		a.messages = msgs
+		a.status = "Updated " + time.Now().Format("15:04:05")
+	case sentEvent:
+		if e.err != nil {
+			a.status = "Send failed: " + e.err.Error()
+			return false
+		}
+		a.status = "Sent"
+		if e.chatID == a.openChat {
+			go a.loadMessages(ctx, e.chatID, events)
+		}
+	case keyEvent:
+		return a.handleKey(ctx, tty.Key(e), events)
+	}
+	return false
+}
+
+func (a *app) handleKey(ctx context.Context, k tty.Key, events chan<- any) bool {
+	switch k.Name {
+	case tty.KeyInterrupt:
+		return true
+	case tty.KeyTab:
+		if a.focus == focusChats && a.openChat != "" {
+			a.focus = focusCompose
+		} else {
+			a.focus = focusChats
+		}
+		return false
+	case tty.KeyPageUp:
+		a.scroll += a.historyRows() / 2
+		return false
+	case tty.KeyPageDown:
+		a.scroll -= a.historyRows() / 2
+		if a.scroll < 0 {
+			a.scroll = 0
+		}
+		return false
+	}
+
+	if a.focus == focusChats {
+		switch {
+		case k.Name == tty.KeyUp || k.Rune == 'k':
+			if a.selected > 0 {
+				a.selected--
+			}
+		case k.Name == tty.KeyDown || k.Rune == 'j':
+			if a.selected < len(a.chats)-1 {
+				a.selected++
+			}
+		case k.Name == tty.KeyEnter:
+			if a.selected < len(a.chats) {
+				a.openChat = a.chats[a.selected].ID
+				a.messages = nil
+				a.scroll = 0
+				a.focus = focusCompose
+				a.status = "Loading messages…"
+				go a.loadMessages(ctx, a.openChat, events)
+			}
+		case k.Rune == 'r':
+			a.status = "Refreshing chats…"
+			go a.loadChats(ctx, events)
+		case k.Rune == 'q' || k.Name == tty.KeyEscape:
+			return true
+		}
+		return false
+	}
+
+	switch k.Name {
+	case tty.KeyEscape:
+		a.focus = focusChats
+	case tty.KeyEnter:
+		text := strings.TrimSpace(string(a.compose))
+		if text == "" {
+			return false
+		}
+		a.compose = nil
+		a.scroll = 0
+		a.status = "Sending…"
+		go a.send(ctx, a.openChat, text, events)
+	case tty.KeyBackspace:
+		if len(a.compose) > 0 {
+			a.compose = a.compose[:len(a.compose)-1]
+		}
+	case tty.KeyClearLine:
+		a.compose = nil
+	case "":
+		if k.Rune >= ' ' {
+			a.compose = append(a.compose, k.Rune)
+		}
+	}
+	return false
+}
+
+// Layout: the chat list on the left, history on the right, then a status
+// line and the compose line along the bottom.
+func (a *app) listWidth() int {
+	w := a.width / 3
+	if w > 32 {
+		w = 32
+	}
+	return w
+}
+
+func (a *app) historyRows() int {
+	return max(a.height-2, 1)
+}
+
+func (a *app) render() string {
+	rows := a.historyRows()
+	lw := a.listWidth()
+	hw := max(a.width-lw-1, 1)
+
+	// Keep the selected chat visible.
+	if a.selected < a.chatTop {
+		a.chatTop = a.selected
+	}
+	if a.selected >= a.chatTop+rows {
+		a.chatTop = a.selected - rows + 1
+	}
+
+	history := a.historyLines(hw)
+	maxScroll := max(len(history)-rows, 0)
+	if a.scroll > maxScroll {
+		a.scroll = maxScroll
+	}
+	start := max(len(history)-rows-a.scroll, 0)
+	history = history[start:]
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for row := 0; row < rows; row++ {
+		i := a.chatTop + row
+		cell := ""
+		if i < len(a.chats) {
+			cell = fit(graph.ChatDisplayName(a.chats[i]), lw)
+		} else {
+			cell = strings.Repeat(" ", lw)
+		}
+		switch {
+		case i < len(a.chats) && i == a.selected && a.focus == focusChats:
+			b.WriteString("\x1b[7m" + cell + "\x1b[0m")
+		case i < len(a.chats) && a.chats[i].ID == a.openChat:
+			b.WriteString("\x1b[1m" + cell + "\x1b[0m")
+		default:
+			b.WriteString(cell)
+		}
+		b.WriteString("\x1b[2m│\x1b[0m")
+		if row < len(history) {
+			b.WriteString(history[row])
+		}
+		b.WriteString("\x1b[K\r\n")
+	}
+
+	help := "Tab: switch  ↑↓: select  Enter: open/send  PgUp/PgDn: scroll  r: refresh  q: quit"
+	b.WriteString("\x1b[7m" + fit(a.status+"  —  "+help, a.width) + "\x1b[0m\r\n")
+
+	prompt := "> "
+	if a.focus != focusCompose {
+		prompt = "  "
+	}
+	line := string(a.compose)
+	if avail := a.width - len(prompt) - 1; len([]rune(line)) > avail && avail > 0 {
+		r := []rune(line)
+		line = string(r[len(r)-avail:])
+	}
+	b.WriteString(prompt + line + "\x1b[K")
+	if a.focus == focusCompose {
+		b.WriteString("\x1b[?25h")
+	} else {
+		b.WriteString("\x1b[?25l")
+	}
+	return b.String()
+}
+
+// historyLines renders the open chat's messages wrapped to width.
+func (a *app) historyLines(width int) []string {
+	if a.openChat == "" {
+		return []string{"Select a chat and press Enter."}
+	}
+	var lines []string
+	for _, m := range a.messages {
+		header := fmt.Sprintf("\x1b[1m%s\x1b[0m \x1b[2m%s\x1b[0m", graph.SenderName(m), shortTime(m.CreatedAt))
+		lines = append(lines, header)
+		text := markup.PlainText(m.Body.Content)
+		if m.DeletedAt != "" {
+			text = "(deleted)"
+		}
+		for _, para := range strings.Split(text, "\n") {
+			for _, l := range wrap(para, width-2) {
+				lines = append(lines, "  "+l)
+			}
+		}
+		if r := graph.ReactionSummary(m); r != "" {
+			lines = append(lines, "  \x1b[2m"+r+"\x1b[0m")
+		}
+	}
+	return lines
+}
+
+func shortTime(ts string) string {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ts
+	}
+	t = t.Local()
+	if time.Since(t) < 24*time.Hour {
+		return t.Format("15:04")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// fit truncates or pads s to exactly width runes.
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		if width > 1 {
+			return string(r[:width-1]) + "…"
+		}
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+// wrap breaks s into lines of at most width runes, preferring spaces.
+func wrap(s string, width int) []string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return []string{s}
+	}
+	var lines []string
+	for len(r) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if r[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(r[:cut]), " "))
+		r = []rune(strings.TrimLeft(string(r[cut:]), " "))
+	}
+	return append(lines, string(r))
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/piotrwolkowski/tcli/internal/graph"
+	"github.com/piotrwolkowski/tcli/internal/tty"
+)
+
+type fakeBackend struct {
+	sent []string
+}
+
+func (f *fakeBackend) ListChats(ctx context.Context) ([]graph.Chat, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) ListMessages(ctx context.Context, chatID string, limit int) ([]graph.Message, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) SendMessage(ctx context.Context, chatID, content string) (*graph.SendMessageResponse, error) {
+	f.sent = append(f.sent, chatID+":"+content)
+	return &graph.SendMessageResponse{ID: "1"}, nil
+}
+
+func TestOpenChatAndSend(t *testing.T) {
+	ctx := context.Background()
+	events := make(chan any, 8)
+	backend := &fakeBackend{}
+	a := &app{backend: backend, width: 80, height: 24}
+
+	a.update(ctx, chatsEvent{chats: []graph.Chat{
+		{ID: "old", Topic: "Old", LastUpdated: "2026-01-01T00:00:00Z"},
+		{ID: "new", Topic: "New", LastUpdated: "2026-02-01T00:00:00Z"},
+	}}, events)
+	if a.chats[0].ID != "new" {
+		t.Fatalf("chats not sorted by activity: first is %q", a.chats[0].ID)
+	}
+
+	a.update(ctx, keyEvent{Name: tty.KeyDown}, events)
+	a.update(ctx, keyEvent{Name: tty.KeyEnter}, events)
+	if a.openChat != "old" || a.focus != focusCompose {
+		t.Fatalf("openChat = %q, focus = %v; want old chat with compose focus", a.openChat, a.focus)
+	}
+	<-events // initial message load
+
+	for _, r := range "hi" {
+		a.update(ctx, keyEvent{Rune: r}, events)
+	}
+	a.update(ctx, keyEvent{Name: tty.KeyEnter}, events)
+	if ev, ok := (<-events).(sentEvent); !ok || ev.err != nil {
+		t.Fatalf("expected successful sentEvent, got %#v", ev)
+	}
+	if len(backend.sent) != 1 || backend.sent[0] != "old:hi" {
+		t.Errorf("sent = %v, want [old:hi]", backend.sent)
+	}
+	if len(a.compose) != 0 {
+		t.Errorf("compose not cleared: %q", string(a.compose))
+	}
+}
+
+func TestStaleMessagesIgnored(t *testing.T) {
+	a := &app{openChat: "b"}
+	a.update(context.Background(), messagesEvent{chatID: "a", messages: []graph.Message{{ID: "1"}}}, nil)
+	if len(a.messages) != 0 {
+		t.Errorf("messages for another chat were applied")
+	}
+}
+
+func TestMessagesDisplayedOldestFirst(t *testing.T) {
+	a := &app{openChat: "c", width: 60, height: 10}
+	a.update(context.Background(), messagesEvent{chatID: "c", messages: []graph.Message{
+		{ID: "2", MessageType: "message", Body: graph.MessageBody{Content: "second"}},
+		{ID: "x", MessageType: "systemEventMessage"},
+		{ID: "1", MessageType: "message", Body: graph.MessageBody{Content: "<p>first</p>"}},
+	}}, nil)
+	out := a.render()
+	if i, j := strings.Index(out, "first"), strings.Index(out, "second"); i < 0 || j < 0 || i > j {
+		t.Errorf("expected first before second in render output")
+	}
+	if len(a.messages) != 2 {
+		t.Errorf("system messages should be hidden, got %d messages", len(a.messages))
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"hello brave new world", 11, []string{"hello brave", "new world"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+	}
+	for _, tt := range tests {
+		got := wrap(tt.in, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrap(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}